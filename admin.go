@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var adminToken string // Общий секрет для /admin/*; пустой отключает админские эндпоинты
+
+// requireAdmin проверяет токен из заголовка Authorization: Bearer <token>.
+// Если админка отключена или токен неверный, ответ уже записан и возвращается false.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if adminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// adminPinsHandler управляет закрепленными статьями:
+// GET — список, POST — закрепить статью из JSON-тела, DELETE ?url= — открепить.
+func adminPinsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var a Article
+		if err := json.NewDecoder(r.Body).Decode(&a); err != nil || a.URL == "" || a.Title == "" {
+			http.Error(w, "Article with url and title is required", http.StatusBadRequest)
+			return
+		}
+		pins.Add(a)
+		pageCache.Clear()
+		log.Printf("Pinned article: %s", a.URL)
+	case http.MethodDelete:
+		articleURL := r.URL.Query().Get("url")
+		if !pins.Remove(articleURL) {
+			http.Error(w, "Article is not pinned", http.StatusNotFound)
+			return
+		}
+		pageCache.Clear()
+		log.Printf("Unpinned article: %s", articleURL)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(pins.List()); err != nil {
+		log.Printf("Error encoding pins: %v", err)
+	}
+}
+
+// AdminStats — ответ /admin/stats.
+type AdminStats struct {
+	Keys        []KeyStatus  `json:"keys"` // С квотой каждого ключа
+	MostClicked []ClickCount `json:"mostClicked"`
+}
+
+// adminStatsHandler отдает служебную статистику в JSON.
+func adminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	stats := AdminStats{
+		Keys:        apiKeys.Status(time.Now()),
+		MostClicked: clicks.Top(10),
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("Error encoding stats: %v", err)
+	}
+}
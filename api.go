@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// apiError — тело ответа JSON API при ошибке.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// apiSearchHandler отдает результаты поиска в JSON. Принимает те же q и page,
+// что и /search, а также pageSize.
+func apiSearchHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query, err := sanitizeQuery(params.Get("q"))
+	if err == nil {
+		query, err = normalizeQuery(query)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, queryErrorMessage(err))
+		return
+	}
+
+	page, err := parsePage(params.Get("page"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid page number")
+		return
+	}
+
+	pageSize, err := parsePageSize(params.Get("pageSize"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid page size")
+		return
+	}
+
+	results, err := searchNews(r.Context(), newsQuery{Query: query}, pageSize, page)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Client went away before news arrived: %v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting news: %v", err)
+		status, msg := newsErrorStatus(w, err)
+		writeJSONError(w, status, msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// prefersJSON сообщает, просит ли клиент в Accept JSON охотнее, чем HTML.
+// Явный text/html сравнивается с application/json по q; шаблоны вроде */*
+// (их шлют браузеры) при равном q уступают явному application/json. Без
+// application/json в Accept всегда отдается HTML.
+func prefersJSON(r *http.Request) bool {
+	jsonQ, htmlQ, wildcardQ := -1.0, -1.0, -1.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/json":
+			jsonQ = max(jsonQ, q)
+		case "text/html":
+			htmlQ = max(htmlQ, q)
+		case "*/*", "text/*":
+			wildcardQ = max(wildcardQ, q)
+		}
+	}
+	if jsonQ <= 0 {
+		return false
+	}
+	if htmlQ >= 0 {
+		return jsonQ > htmlQ
+	}
+	return jsonQ >= wildcardQ
+}
+
+// searchJSON отвечает на /search с Accept: application/json: та же выдача,
+// что и в HTML, в JSON-виде Search (как в /export.json). Ошибки runSearch
+// приходят в формате JSON API.
+func searchJSON(w http.ResponseWriter, r *http.Request) {
+	if params, err := searchParams(r); err == nil {
+		if query, err := sanitizeQuery(params.Get("q")); err == nil && query == "" {
+			writeJSONError(w, http.StatusBadRequest, "Missing search query")
+			return
+		}
+	}
+
+	jw := &jsonErrorWriter{ResponseWriter: w}
+	search, ok := runSearch(jw, r, false)
+	if !ok {
+		jw.finish()
+		return
+	}
+	writeJSON(w, http.StatusOK, search)
+}
+
+// jsonErrorWriter переписывает ответы http.Error в {"error": msg}. Остальные
+// ответы (редиректы, 204) проходят как есть.
+type jsonErrorWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (j *jsonErrorWriter) WriteHeader(status int) {
+	if status < 400 {
+		j.ResponseWriter.WriteHeader(status)
+		return
+	}
+	j.status = status
+}
+
+func (j *jsonErrorWriter) Write(b []byte) (int, error) {
+	if j.status == 0 {
+		return j.ResponseWriter.Write(b)
+	}
+	return j.body.Write(b)
+}
+
+// finish отправляет накопленную ошибку, если она была.
+func (j *jsonErrorWriter) finish() {
+	if j.status != 0 {
+		writeJSONError(j.ResponseWriter, j.status, strings.TrimSpace(j.body.String()))
+	}
+}
+
+// writeJSON отдает v в JSON с кодом status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// writeJSONError отдает ошибку в виде {"error": msg}.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, apiError{Error: msg})
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	maxArticleBytes    = 2 << 20 // Больше с чужого сайта не читаем
+	minParagraphLength = 40      // Более короткие <p> — подписи, кнопки и прочий мусор
+)
+
+// recentArticles — статьи из недавних выдач. /article открывает только их,
+// чтобы сервис не превращался в прокси для произвольных адресов.
+var recentArticles = newTTLCache[Article](time.Hour, 5000)
+
+// articleBody — запись articleBodies: абзацы статьи или ошибка, с которой
+// их не удалось получить.
+type articleBody struct {
+	paragraphs []string
+	err        error
+}
+
+var articleBodies = newTTLCache[articleBody](10*time.Minute, 200) // Извлеченный текст статей по URL
+
+// articleFailureTTL — сколько помнить неудачную загрузку статьи: медленный
+// или недоступный сайт не должен задерживать каждое открытие /article.
+const articleFailureTTL = time.Minute
+
+// articleClient ходит на сайты изданий. Dialer не дает подключиться к
+// внутренним адресам, даже если к ним ведет DNS или редирект.
+var articleClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: denyInternal}).DialContext,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return validateOutboundURL(req.URL.String())
+	},
+}
+
+var errNoReadableText = errors.New("no readable text found")
+
+// ArticlePage — данные шаблона article.html. Search дает шапке локаль,
+// режим чтения и T.
+type ArticlePage struct {
+	*Search
+	Article    Article
+	Paragraphs []string
+	Fallback   bool // Полный текст получить не удалось, показан текст из NewsAPI
+}
+
+// rememberArticles запоминает статьи выдачи для /article.
+func rememberArticles(articles []Article) {
+	for _, a := range articles {
+		if a.URL != "" {
+			recentArticles.Set(a.URL, a)
+		}
+	}
+}
+
+// articleHandler показывает статью из недавней выдачи с полным текстом,
+// извлеченным со страницы издания, а если это не удалось — с текстом NewsAPI.
+func articleHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("url")
+	article, ok := recentArticles.Get(target)
+	if !ok {
+		notFoundHandler(w, r)
+		return
+	}
+
+	page := &ArticlePage{
+		Search: &Search{
+			ReaderMode:  readerMode(r),
+			Theme:       requestTheme(r),
+			Locale:      requestLocale(r),
+			TrackClicks: trackClicks,
+			Secure:      isSecureRequest(r),
+			Trusted:     trustedSources,
+		},
+		Article: article,
+	}
+
+	paragraphs, err := fullText(r.Context(), target)
+	if err != nil {
+		log.Printf("Falling back to NewsAPI text for %s: %v", target, err)
+		page.Fallback = true
+		paragraphs = fallbackText(article)
+	}
+	page.Paragraphs = paragraphs
+
+	var buf bytes.Buffer // Чтобы ошибка шаблона не оставила полстраницы с кодом 200
+	if err := templates().Article.Execute(&buf, page); err != nil {
+		log.Printf("Error executing article template: %v", err)
+		renderError(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// fullText загружает страницу статьи и извлекает из нее абзацы текста.
+// Результат кэшируется в articleBodies, неудача — на articleFailureTTL.
+// Отмененный посетителем запрос не кэшируется: сайт тут ни при чем.
+func fullText(ctx context.Context, target string) ([]string, error) {
+	if body, ok := articleBodies.Get(target); ok {
+		return body.paragraphs, body.err
+	}
+
+	paragraphs, err := fetchFullText(ctx, target)
+	switch {
+	case err == nil:
+		articleBodies.Set(target, articleBody{paragraphs: paragraphs})
+	case ctx.Err() == nil:
+		articleBodies.SetWithTTL(target, articleBody{err: err}, articleFailureTTL)
+	}
+	return paragraphs, err
+}
+
+// fetchFullText — fullText без кэша.
+func fetchFullText(ctx context.Context, target string) ([]string, error) {
+	if err := validateOutboundURL(target); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "News-Site article reader")
+
+	resp, err := articleClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("not an HTML page: %q", mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxArticleBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	paragraphs := extractParagraphs(string(body))
+	if len(paragraphs) == 0 {
+		return nil, errNoReadableText
+	}
+	return paragraphs, nil
+}
+
+var (
+	// boilerplatePattern — блоки, в которых текста статьи не бывает. В RE2 нет
+	// обратных ссылок, поэтому каждый тег перечислен отдельно.
+	boilerplatePattern = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<noscript\b.*?</noscript\s*>|<nav\b.*?</nav\s*>|<header\b.*?</header\s*>|<footer\b.*?</footer\s*>|<aside\b.*?</aside\s*>|<form\b.*?</form\s*>|<!--.*?-->`)
+	articlePattern     = regexp.MustCompile(`(?is)<article\b[^>]*>(.*)</article\s*>`)
+	paragraphPattern   = regexp.MustCompile(`(?is)<p\b[^>]*>(.*?)</p\s*>`)
+	spacePattern       = regexp.MustCompile(`\s+`)
+)
+
+// extractParagraphs — простая эвристика в духе readability: берет абзацы
+// <p> из <article> (или всей страницы, если там пусто), выбрасывая служебные
+// блоки и слишком короткие абзацы. Возвращает обычный текст без разметки,
+// так что в шаблоне он экранируется целиком.
+func extractParagraphs(page string) []string {
+	page = boilerplatePattern.ReplaceAllString(page, " ")
+	if m := articlePattern.FindStringSubmatch(page); m != nil {
+		if paragraphs := paragraphsIn(m[1]); len(paragraphs) > 0 {
+			return paragraphs
+		}
+	}
+	return paragraphsIn(page)
+}
+
+func paragraphsIn(fragment string) []string {
+	var paragraphs []string
+	for _, m := range paragraphPattern.FindAllStringSubmatch(fragment, -1) {
+		text := spacePattern.ReplaceAllString(stripHTML(m[1]), " ")
+		if len(text) >= minParagraphLength {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return paragraphs
+}
+
+// fallbackText — текст статьи из ответа NewsAPI без маркера обрезки.
+func fallbackText(a Article) []string {
+	text := strings.TrimSpace(truncatedMarker.ReplaceAllString(stripHTML(a.Content), ""))
+	if text == "" {
+		text = stripHTML(a.Description)
+	}
+	if text == "" {
+		return nil
+	}
+	return []string{text}
+}
+
+// denyInternal запрещает соединения с внутренними адресами уже после
+// разрешения имени, чтобы через DNS нельзя было достучаться до локальной сети.
+func denyInternal(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+		return fmt.Errorf("refusing to connect to internal address %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFullTextCachesFailuresBriefly(t *testing.T) {
+	oldBodies := articleBodies
+	defer func() { articleBodies = oldBodies }()
+	now := time.Unix(1_700_000_000, 0)
+	articleBodies = newTTLCache[articleBody](10*time.Minute, 10)
+	articleBodies.now = func() time.Time { return now }
+
+	// Внутренний адрес отклоняется без сетевого запроса
+	target := "http://127.0.0.1/story"
+	if _, err := fullText(context.Background(), target); err == nil {
+		t.Fatal("fullText of an internal address succeeded")
+	}
+	if body, ok := articleBodies.Get(target); !ok || body.err == nil {
+		t.Fatalf("failure not cached: %+v, %t", body, ok)
+	}
+
+	now = now.Add(articleFailureTTL)
+	if _, ok := articleBodies.Get(target); ok {
+		t.Error("failure still cached after articleFailureTTL")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fullText(ctx, "http://127.0.0.1/other")
+	if _, ok := articleBodies.Get("http://127.0.0.1/other"); ok {
+		t.Error("failure of a cancelled request was cached")
+	}
+
+	articleBodies.Set("https://example.com/a", articleBody{paragraphs: []string{"Text"}})
+	if got, err := fullText(context.Background(), "https://example.com/a"); err != nil || len(got) != 1 {
+		t.Errorf("cached article = %v, %v; want its paragraphs", got, err)
+	}
+}
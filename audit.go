@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var auditLogger *slog.Logger // Журнал аудита поисковых запросов; nil — аудит выключен
+
+var auditHashIP = true // Писать в журнал аудита хэш IP вместо самого адреса
+
+// openAuditLog настраивает журнал аудита в формате JSON lines.
+// Пустой путь отключает аудит, "-" пишет в stdout.
+func openAuditLog(path string) (*slog.Logger, error) {
+	var out io.Writer
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		out = os.Stdout
+	default:
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		out = f
+	}
+	return slog.New(slog.NewJSONHandler(out, nil)), nil
+}
+
+// searchAudit — запись журнала аудита о поиске. Она хранится и в кэше
+// страниц, чтобы повторный поиск, отданный из кэша, тоже попадал в журнал.
+type searchAudit struct {
+	query        string
+	page         int
+	locale       string
+	trustedOnly  bool
+	params       map[string]string // Все действующие параметры поиска, как в Search.query
+	resultCount  int
+	totalResults int
+}
+
+// newSearchAudit собирает запись аудита по выполненному поиску.
+func newSearchAudit(s *Search) searchAudit {
+	params := map[string]string{}
+	for name, values := range s.query(s.CurrentPage) {
+		if values[0] != "" {
+			params[name] = values[0]
+		}
+	}
+	return searchAudit{
+		query:        s.SearchKey,
+		page:         s.CurrentPage,
+		locale:       s.Locale,
+		trustedOnly:  s.TrustedOnly,
+		params:       params,
+		resultCount:  len(s.Results.Articles),
+		totalResults: s.Results.TotalResults,
+	}
+}
+
+// auditSearch записывает в журнал аудита фактические параметры поиска.
+func auditSearch(r *http.Request, a searchAudit) {
+	if auditLogger == nil {
+		return
+	}
+
+	ip := clientIP(r)
+	if auditHashIP {
+		ip = hashIP(ip)
+	}
+
+	auditLogger.Info("search",
+		slog.String("query", a.query),
+		slog.Int("page", a.page),
+		slog.String("locale", a.locale),
+		slog.Bool("trusted_only", a.trustedOnly),
+		slog.Any("params", a.params),
+		slog.String("client_ip", ip),
+		slog.Int("result_count", a.resultCount),
+		slog.Int("total_results", a.totalResults),
+	)
+}
+
+// clientIP возвращает IP клиента из RemoteAddr, а с -trustproxy — последний
+// адрес из X-Forwarded-For, который дописал наш прокси. Адресам левее в
+// заголовке верить нельзя: их присылает сам клиент.
+func clientIP(r *http.Request) string {
+	if trustProxy {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// hashIP возвращает короткий HMAC-SHA256 адреса с ключом -cookiesecret, чтобы
+// не хранить сам IP. Без ключа хэш IPv4 подбирается перебором всех адресов.
+// Если -cookiesecret не задан, хэши одного адреса после перезапуска разные.
+func hashIP(ip string) string {
+	mac := hmac.New(sha256.New, cookieSecret)
+	mac.Write([]byte("audit-ip=" + ip)) // Префикс отделяет эти хэши от подписей cookie
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
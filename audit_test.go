@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// auditEntry записывает один поиск в журнал аудита и возвращает разобранную запись.
+func auditEntry(t *testing.T, s *Search) map[string]interface{} {
+	t.Helper()
+	var buf bytes.Buffer
+	auditLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { auditLogger = nil }()
+
+	r := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	auditSearch(r, newSearchAudit(s))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("audit log line %q: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func TestAuditSearch(t *testing.T) {
+	defer func() { auditHashIP = true }()
+	s := &Search{
+		SearchKey:   "go",
+		CurrentPage: 2,
+		Locale:      "ru",
+		TrustedOnly: true,
+		Results:     Results{TotalResults: 45, Articles: make([]Article, 20)},
+		Language:    "de",
+		SortBy:      "popularity",
+		From:        "2024-05-01",
+		Domains:     "bbc.co.uk",
+		SearchIn:    "title",
+	}
+
+	auditHashIP = true
+	entry := auditEntry(t, s)
+	want := map[string]interface{}{
+		"msg":           "search",
+		"query":         "go",
+		"page":          float64(2),
+		"locale":        "ru",
+		"trusted_only":  true,
+		"result_count":  float64(20),
+		"total_results": float64(45),
+		"client_ip":     hashIP("203.0.113.7"),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+	wantParams := map[string]interface{}{
+		"q": "go", "page": "2", "trusted": "1", "lang": "de", "sortBy": "popularity",
+		"from": "2024-05-01", "domains": "bbc.co.uk", "searchIn": "title",
+	}
+	if params, _ := entry["params"].(map[string]interface{}); !reflect.DeepEqual(params, wantParams) {
+		t.Errorf("params = %v, want %v", entry["params"], wantParams)
+	}
+	if entry["client_ip"] == "203.0.113.7" || len(hashIP("203.0.113.7")) != 16 {
+		t.Errorf("client_ip = %v, want a 16-character hash", entry["client_ip"])
+	}
+
+	auditHashIP = false
+	if entry := auditEntry(t, s); entry["client_ip"] != "203.0.113.7" {
+		t.Errorf("client_ip with hashing off = %v, want the raw address", entry["client_ip"])
+	}
+}
+
+func TestAuditSearchOnPageCacheHit(t *testing.T) {
+	var calls int
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	pageCache = newTTLCache[renderedPage](time.Minute, 10)
+	var buf bytes.Buffer
+	auditLogger = slog.New(slog.NewJSONHandler(&buf, nil))
+	defer func() { auditLogger = nil }()
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&sortBy=popularity", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("NewsAPI calls = %d, want the second page from the cache", calls)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d entries, want one per search:\n%s", len(lines), buf.String())
+	}
+	var entry struct {
+		Query       string            `json:"query"`
+		Params      map[string]string `json:"params"`
+		ResultCount int               `json:"result_count"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("audit log line %q: %v", lines[1], err)
+	}
+	if entry.Query != "golang" || entry.Params["sortBy"] != "popularity" || entry.ResultCount != 20 {
+		t.Errorf("cached search audit = %+v, want the original search", entry)
+	}
+}
+
+func TestHashIPIsKeyed(t *testing.T) {
+	oldSecret := cookieSecret
+	defer func() { cookieSecret = oldSecret }()
+
+	cookieSecret = []byte("first secret")
+	first := hashIP("203.0.113.7")
+	if len(first) != 16 || first != hashIP("203.0.113.7") {
+		t.Fatalf("hashIP = %q, want a stable 16-character hash", first)
+	}
+	plain := sha256.Sum256([]byte("203.0.113.7"))
+	if first == hex.EncodeToString(plain[:8]) {
+		t.Error("hashIP is a plain SHA-256 of the address")
+	}
+
+	cookieSecret = []byte("second secret")
+	if hashIP("203.0.113.7") == first {
+		t.Error("hashIP does not depend on the secret")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	bookmarksCookie        = "bookmarks"
+	maxBookmarks           = 30
+	maxBookmarksCookieSize = 3500 // Байт значения cookie; браузеры хранят не больше 4096 вместе с именем и атрибутами
+	maxBookmarkTitleLength = 150  // Рун заголовка; длиннее обрезаем, чтобы влезло больше закладок
+	bookmarksMaxAge        = 365 * 24 * 60 * 60
+)
+
+// bookmark — статья в списке "прочитать позже". Ключи короткие: весь список
+// живет в одной cookie.
+type bookmark struct {
+	URL       string `json:"u"`
+	Title     string `json:"t"`
+	Source    string `json:"s,omitempty"`
+	Published int64  `json:"p,omitempty"` // Unix-время публикации; 0 — неизвестно
+}
+
+func (b bookmark) article() Article {
+	a := Article{URL: b.URL, Title: b.Title, Source: Source{Name: b.Source}}
+	if b.Published != 0 {
+		a.PublishedAt = time.Unix(b.Published, 0).UTC()
+	}
+	return a
+}
+
+// bookmarks возвращает сохраненные статьи посетителя, от новых к старым.
+// Подделанная или испорченная cookie считается пустой.
+func bookmarks(r *http.Request) []bookmark {
+	var saved []bookmark
+	if !readSignedCookie(r, bookmarksCookie, &saved) {
+		return nil
+	}
+	return saved
+}
+
+// bookmarkedURLs — адреса сохраненных статей для кнопок в выдаче.
+func bookmarkedURLs(r *http.Request) map[string]bool {
+	saved := bookmarks(r)
+	if len(saved) == 0 {
+		return nil
+	}
+	urls := make(map[string]bool, len(saved))
+	for _, b := range saved {
+		urls[b.URL] = true
+	}
+	return urls
+}
+
+// saveBookmarks записывает список в cookie. Если он не укладывается в
+// maxBookmarks или maxBookmarksCookieSize, самые старые закладки вытесняются.
+func saveBookmarks(w http.ResponseWriter, r *http.Request, saved []bookmark) {
+	if len(saved) > maxBookmarks {
+		saved = saved[:maxBookmarks]
+	}
+	value := signedCookieValue(bookmarksCookie, saved)
+	for len(value) > maxBookmarksCookieSize && len(saved) > 0 {
+		saved = saved[:len(saved)-1]
+		value = signedCookieValue(bookmarksCookie, saved)
+	}
+	setSignedCookie(w, r, bookmarksCookie, value, bookmarksMaxAge)
+}
+
+// bookmarkHandler добавляет статью в закладки (action=add) или убирает ее
+// (action=remove) и возвращает посетителя на прежнюю страницу. Для статей из
+// недавних выдач заголовок и источник берутся из них, а не из формы.
+func bookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !sameOriginPost(r) {
+		slog.WarnContext(r.Context(), "Rejected cross-origin bookmark form", "origin", r.Header.Get("Origin"), "referer", r.Referer())
+		renderError(w, r, http.StatusForbidden, "Cross-origin form submission")
+		return
+	}
+	articleURL := r.PostFormValue("url")
+	if u, err := url.Parse(articleURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		renderError(w, r, http.StatusBadRequest, "Invalid article URL")
+		return
+	}
+
+	var saved []bookmark
+	for _, b := range bookmarks(r) {
+		if b.URL != articleURL {
+			saved = append(saved, b)
+		}
+	}
+
+	switch r.PostFormValue("action") {
+	case "add":
+		b := bookmark{URL: articleURL, Title: r.PostFormValue("title")}
+		if a, ok := recentArticles.Get(articleURL); ok {
+			b.Title, b.Source = a.Title, a.Source.DisplayName()
+			if a.HasPublishedDate() {
+				b.Published = a.PublishedAt.Unix()
+			}
+		}
+		if b.Title == "" {
+			b.Title = articleURL
+		}
+		b.Title = truncateRunes(b.Title, maxBookmarkTitleLength)
+		saved = append([]bookmark{b}, saved...)
+	case "remove":
+	default:
+		renderError(w, r, http.StatusBadRequest, "Invalid action: use add or remove")
+		return
+	}
+
+	saveBookmarks(w, r, saved)
+	http.Redirect(w, r, backURL(r), http.StatusSeeOther)
+}
+
+// sameOriginPost сообщает, отправлена ли форма с нашего сайта. Cookie
+// закладок подписана, но браузер приложит ее и к форме с чужого сайта,
+// поэтому без проверки любой сайт мог бы менять закладки посетителя.
+// Современные браузеры присылают Sec-Fetch-Site и Origin с каждым POST;
+// Referer смотрим, только если их нет. Запрос без всех трех заголовков
+// пришел не из браузера, и подделать его чужой страницей нельзя.
+func sameOriginPost(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "same-origin" || site == "none"
+	}
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Referer()
+		if source == "" {
+			return true
+		}
+	}
+	u, err := url.Parse(source)
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
+
+// bookmarksHandler показывает сохраненные статьи тем же блоком "articles",
+// что и выдача поиска.
+func bookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	search := &Search{
+		CurrentPage:   1,
+		ReaderMode:    readerMode(r),
+		Theme:         requestTheme(r),
+		Locale:        requestLocale(r),
+		TrackClicks:   trackClicks,
+		Secure:        isSecureRequest(r),
+		Trusted:       trustedSources,
+		SortBy:        defaultSortBy,
+		BookmarksPage: true,
+	}
+	for _, b := range bookmarks(r) {
+		search.Results.Articles = append(search.Results.Articles, b.article())
+	}
+	search.Bookmarked = bookmarkedURLs(r)
+	search.PageTitle = translateText(search.Locale, "bookmarks.title")
+	search.Description = search.PageTitle
+	search.CanonicalURL = siteURL(r) + "/bookmarks"
+
+	var buf bytes.Buffer
+	if err := templates().Index.Execute(&buf, search); err != nil {
+		slog.ErrorContext(r.Context(), "Error executing template", "error", err)
+		renderError(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Add("Vary", "Cookie")
+	w.Write(buf.Bytes())
+}
+
+// truncateRunes обрезает s до n рун, добавляя многоточие.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
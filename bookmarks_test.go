@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postBookmark отправляет форму /bookmark с cookie и возвращает ответ.
+func postBookmark(t *testing.T, cookies []*http.Cookie, action, articleURL, title string) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"action": {action}, "url": {articleURL}, "title": {title}}
+	req := httptest.NewRequest(http.MethodPost, "/bookmark", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	bookmarkHandler(rec, req)
+	return rec
+}
+
+func TestBookmarks(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+
+	rec := postBookmark(t, nil, "add", "https://example.com/a", "First <story>")
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("add: status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	rec = postBookmark(t, rec.Result().Cookies(), "add", "https://example.com/b", "Second story")
+	cookies := rec.Result().Cookies()
+
+	req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	saved := bookmarks(req)
+	if len(saved) != 2 || saved[0].URL != "https://example.com/b" || saved[1].Title != "First <story>" {
+		t.Fatalf("bookmarks = %+v, want b then a", saved)
+	}
+
+	rec = httptest.NewRecorder()
+	bookmarksHandler(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{"Second story", "First &lt;story&gt;", `value="remove"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/bookmarks does not contain %s", want)
+		}
+	}
+
+	rec = postBookmark(t, cookies, "remove", "https://example.com/b", "")
+	req = httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	if saved := bookmarks(req); len(saved) != 1 || saved[0].URL != "https://example.com/a" {
+		t.Errorf("after remove: bookmarks = %+v, want only a", saved)
+	}
+}
+
+func TestBookmarkRejectsBadInput(t *testing.T) {
+	for _, tt := range []struct{ action, url string }{
+		{"add", "javascript:alert(1)"},
+		{"add", ""},
+		{"toggle", "https://example.com/a"},
+	} {
+		if rec := postBookmark(t, nil, tt.action, tt.url, "x"); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s %q: status = %d, want %d", tt.action, tt.url, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+	req.AddCookie(&http.Cookie{Name: bookmarksCookie, Value: signedCookieValue("recent", []bookmark{{URL: "https://evil.example"}})})
+	if saved := bookmarks(req); saved != nil {
+		t.Errorf("cookie signed for another name: bookmarks = %+v, want none", saved)
+	}
+}
+
+func TestSaveBookmarksEvictsOldest(t *testing.T) {
+	var saved []bookmark
+	for i := 0; i < maxBookmarks+10; i++ {
+		saved = append(saved, bookmark{URL: fmt.Sprintf("https://example.com/%d", i), Title: strings.Repeat("long title ", 12)})
+	}
+	rec := httptest.NewRecorder()
+	saveBookmarks(rec, httptest.NewRequest(http.MethodPost, "/bookmark", nil), saved)
+
+	c := rec.Result().Cookies()[0]
+	if len(c.Value) > maxBookmarksCookieSize {
+		t.Errorf("cookie is %d bytes, want at most %d", len(c.Value), maxBookmarksCookieSize)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/bookmarks", nil)
+	req.AddCookie(c)
+	kept := bookmarks(req)
+	if len(kept) == 0 || len(kept) >= maxBookmarks || kept[0].URL != "https://example.com/0" {
+		t.Errorf("kept %d bookmarks, want fewer than %d starting with the newest", len(kept), maxBookmarks)
+	}
+}
+
+func TestBookmarkRejectsCrossOrigin(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"same origin", map[string]string{"Origin": "http://example.com"}, http.StatusSeeOther},
+		{"same-origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://example.com"}, http.StatusSeeOther},
+		{"referer only", map[string]string{"Referer": "http://example.com/search?q=go"}, http.StatusSeeOther},
+		{"no browser headers", nil, http.StatusSeeOther},
+		{"foreign origin", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"opaque origin", map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "http://example.com"}, http.StatusForbidden},
+		{"same-site fetch", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+		{"foreign referer", map[string]string{"Referer": "https://evil.example/page"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		form := url.Values{"action": {"add"}, "url": {"https://example.com/a"}, "title": {"Story"}}
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/bookmark", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		bookmarkHandler(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusForbidden && len(rec.Result().Cookies()) != 0 {
+			t.Errorf("%s: rejected form still set %v", tt.name, rec.Result().Cookies())
+		}
+	}
+}
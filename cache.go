@@ -0,0 +1,103 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type cacheItem[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// ttlCache — потокобезопасный кэш, записи которого устаревают через ttl.
+// Если задан maxEntries, при переполнении вытесняется давно не читавшаяся запись.
+type ttlCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	order      *list.List // В начале списка — недавно использованные записи
+	items      map[string]*list.Element
+	now        func() time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration, maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get возвращает значение, если оно есть и еще не устарело.
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	item := el.Value.(*cacheItem[V])
+	if !c.now().Before(item.expires) {
+		c.remove(el)
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return item.value, true
+}
+
+// Set сохраняет значение. При ttl <= 0 кэш ничего не хранит.
+func (c *ttlCache[V]) Set(key string, value V) {
+	c.SetWithTTL(key, value, c.ttl)
+}
+
+// SetWithTTL сохраняет значение на ttl вместо срока кэша, например чтобы
+// недолго помнить неудачу. Выключенный кэш (ttl кэша <= 0) ничего не хранит.
+func (c *ttlCache[V]) SetWithTTL(key string, value V, ttl time.Duration) {
+	if c.ttl <= 0 || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		item := el.Value.(*cacheItem[V])
+		item.value, item.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheItem[V]{key: key, value: value, expires: expires})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Clear удаляет все записи.
+func (c *ttlCache[V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+// Len возвращает число записей, включая еще не вычищенные устаревшие.
+func (c *ttlCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *ttlCache[V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*cacheItem[V]).key)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const maxTrackedURLs = 10000 // Больше разных адресов не считаем, чтобы счетчик не рос бесконечно
+
+// ClickCount — число переходов по ссылке на статью.
+type ClickCount struct {
+	URL    string `json:"url"`
+	Clicks int    `json:"clicks"`
+}
+
+// clickCounter считает переходы по ссылкам на статьи через /out.
+type clickCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newClickCounter() *clickCounter {
+	return &clickCounter{counts: make(map[string]int)}
+}
+
+// Record засчитывает переход по ссылке.
+func (c *clickCounter) Record(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.counts[target]; !ok && len(c.counts) >= maxTrackedURLs {
+		return
+	}
+	c.counts[target]++
+}
+
+// Top возвращает n самых популярных ссылок по убыванию числа переходов.
+func (c *clickCounter) Top(n int) []ClickCount {
+	c.mu.Lock()
+	top := make([]ClickCount, 0, len(c.counts))
+	for u, clicks := range c.counts {
+		top = append(top, ClickCount{URL: u, Clicks: clicks})
+	}
+	c.mu.Unlock()
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Clicks != top[j].Clicks {
+			return top[i].Clicks > top[j].Clicks
+		}
+		return top[i].URL < top[j].URL
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// OutURL — ссылка на статью через /out. Адрес подписан: /out перенаправляет
+// только по ссылкам, которые показал сам сервис, и не служит открытым
+// редиректом на чужие сайты.
+func (a Article) OutURL() string {
+	target := a.CleanURL()
+	return "/out?" + url.Values{"u": {target}, "sig": {outboundSignature(target)}}.Encode()
+}
+
+// outboundSignature подписывает адрес для /out тем же ключом, что и cookie.
+func outboundSignature(target string) string {
+	return signCookie("out", target)
+}
+
+// validOutboundSignature сообщает, выдал ли сервис подпись sig для target.
+func validOutboundSignature(target, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(outboundSignature(target)))
+}
+
+// validateOutboundURL проверяет, что ссылка ведет на внешний http(s)-адрес:
+// без учетных данных, не на localhost и не на внутренние IP-адреса.
+func validateOutboundURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("only http and https links are allowed")
+	}
+	if u.User != nil {
+		return errors.New("links with credentials are not allowed")
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("link has no host")
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errors.New("links to localhost are not allowed")
+	}
+	if ip := net.ParseIP(host); ip != nil && isInternalIP(ip) {
+		return errors.New("links to internal addresses are not allowed")
+	}
+	return nil
+}
+
+// isInternalIP сообщает, относится ли ip к внутренним адресам, куда нельзя
+// ни перенаправлять посетителей, ни ходить самому сервису.
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flagEnv — переменные окружения, из которых флаги берут значения по
+// умолчанию. Заданная переменная важнее файла конфигурации.
+var flagEnv = map[string][]string{
+	"apikey":         {"APIKEYS", "APIKEY"},
+	"apikeyfile":     {"APIKEY_FILE"},
+	"apiurl":         {"APIURL"},
+	"siteurl":        {"SITE_URL"},
+	"port":           {"PORT"},
+	"tlscert":        {"TLS_CERT"},
+	"tlskey":         {"TLS_KEY"},
+	"admintoken":     {"ADMIN_TOKEN"},
+	"trustedsources": {"TRUSTED_SOURCES"},
+	"alloweddomains": {"ALLOWED_DOMAINS"},
+	"locale":         {"LOCALE"},
+	"auditlog":       {"AUDIT_LOG"},
+	"cookiesecret":   {"COOKIE_SECRET"},
+	"assets":         {"ASSETS_DIR"},
+	"template":       {"TEMPLATE"},
+}
+
+// applyConfigFile читает JSON-объект из path и применяет его к флагам fs.
+// Ключи — имена флагов без учета регистра ("apiKey", "cacheTTL", "pageSize"),
+// значения — строки, числа, true/false или массивы строк (для apikey).
+// Порядок важности: явный флаг > переменная окружения > файл > значение по
+// умолчанию. Пустой path ничего не делает; отсутствующий или битый файл,
+// неизвестный ключ или недопустимое значение — ошибка.
+func applyConfigFile(fs *flag.FlagSet, path string, getenv func(string) string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var settings map[string]interface{}
+	if err := decoder.Decode(&settings); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // Чтобы ошибки не зависели от порядка обхода map
+
+	for _, key := range keys {
+		name := strings.ToLower(key)
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		if explicit[name] || envSet(name, getenv) {
+			continue
+		}
+
+		value, err := configValue(settings[key])
+		if err != nil {
+			return fmt.Errorf("%s: setting %q: %w", path, key, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%s: setting %q: invalid value %q: %w", path, key, value, err)
+		}
+	}
+	return nil
+}
+
+func envSet(name string, getenv func(string) string) bool {
+	for _, env := range flagEnv[name] {
+		if getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// configValue приводит значение из JSON к строке для flag.Value.Set.
+func configValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("list items must be strings")
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configFlags — набор флагов как в main, значения по умолчанию уже взяты из
+// окружения.
+func configFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("config", "", "")
+	fs.String("port", "from-env", "")
+	fs.String("apiurl", "default-url", "")
+	fs.Int("pagesize", 20, "")
+	fs.Bool("nocache", false, "")
+	fs.Duration("cachettl", time.Minute, "")
+	fs.String("alloweddomains", "", "")
+	return fs
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyConfigFilePrecedence(t *testing.T) {
+	path := writeConfig(t, `{"Port": "from-file", "apiURL": "file-url", "pageSize": 30, "noCache": true, "cacheTTL": "2m", "allowedDomains": ["a.com", "b.com"]}`)
+	fs := configFlags()
+	if err := fs.Parse([]string{"-pagesize=10"}); err != nil {
+		t.Fatal(err)
+	}
+	getenv := func(name string) string {
+		if name == "PORT" {
+			return "7000"
+		}
+		return ""
+	}
+
+	if err := applyConfigFile(fs, path, getenv); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	want := map[string]string{
+		"pagesize":       "10",          // Явный флаг важнее файла
+		"port":           "from-env",    // Переменная окружения важнее файла
+		"apiurl":         "file-url",    // Файл важнее значения по умолчанию
+		"nocache":        "true",        // true/false
+		"cachettl":       "2m0s",        // Строка для time.Duration
+		"alloweddomains": "a.com,b.com", // Массив строк
+	}
+	for name, value := range want {
+		if got := fs.Lookup(name).Value.String(); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"unknown key", `{"colour": "red"}`, `unknown setting "colour"`},
+		{"nested config", `{"config": "other.json"}`, `unknown setting "config"`},
+		{"broken JSON", `{"port": `, "unexpected EOF"},
+		{"invalid value", `{"pageSize": "many"}`, `setting "pageSize": invalid value "many"`},
+		{"non-string list", `{"allowedDomains": [1, 2]}`, "list items must be strings"},
+		{"object value", `{"port": {"number": 1}}`, "unsupported value"},
+	}
+	noEnv := func(string) string { return "" }
+	for _, tt := range tests {
+		err := applyConfigFile(configFlags(), writeConfig(t, tt.content), noEnv)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want it to mention %q", tt.name, err, tt.want)
+		}
+	}
+
+	if err := applyConfigFile(configFlags(), filepath.Join(t.TempDir(), "missing.json"), noEnv); !os.IsNotExist(err) {
+		t.Errorf("missing file: error = %v, want not exist", err)
+	}
+	if err := applyConfigFile(configFlags(), "", noEnv); err != nil {
+		t.Errorf("empty path: error = %v, want nil", err)
+	}
+}
+
+func TestConfigValue(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"text", "text"},
+		{json.Number("42"), "42"},
+		{json.Number("1.5"), "1.5"},
+		{true, "true"},
+		{false, "false"},
+		{[]interface{}{"k1", "k2"}, "k1,k2"},
+		{[]interface{}{}, ""},
+	}
+	for _, tt := range tests {
+		got, err := configValue(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("configValue(%#v) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := configValue(nil); err == nil {
+		t.Error("configValue(nil) succeeded, want an error")
+	}
+}
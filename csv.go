@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var csvHeader = []string{"source", "author", "title", "url", "publishedAt", "description"}
+
+// csvExportHandler отдает текущую страницу выдачи в CSV. Параметры те же,
+// что у /search; пустая выдача дает файл из одной строки заголовков.
+func csvExportHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := runSearch(w, r, false)
+	if !ok {
+		return
+	}
+
+	articles := transformArticles(search.Results.Articles, exportWorkers, cleanArticle)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, csvFilename(search.SearchKey, search.CurrentPage)))
+
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, a := range articles {
+		published := ""
+		if a.HasPublishedDate() {
+			published = a.PublishedAt.Format(time.RFC3339)
+		}
+		cw.Write([]string{
+			csvCell(a.Source.Name),
+			csvCell(a.Author),
+			csvCell(a.Title),
+			csvCell(a.URL),
+			published,
+			csvCell(a.Description),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("Error writing CSV export: %v", err)
+	}
+}
+
+// csvCell не дает табличным редакторам принять текст статьи за формулу:
+// значения, начинающиеся с = + - @, предваряются апострофом.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvFilename строит имя файла из запроса: только латиница, цифры и дефисы.
+func csvFilename(query string, page int) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(query) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	if slug == "" {
+		slug = "export"
+	}
+	if page > 1 {
+		return fmt.Sprintf("news-%s-page-%d.csv", slug, page)
+	}
+	return "news-" + slug + ".csv"
+}
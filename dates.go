@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// dateLocale — как писать дату публикации: названия месяцев и порядок
+// частей. В layout %[1]d — день, %[2]s — месяц, %[3]d — год.
+type dateLocale struct {
+	months [12]string
+	layout string
+}
+
+var englishMonths = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+// dateLocales — поддерживаемые значения -locale.
+var dateLocales = map[string]dateLocale{
+	"en-US": {months: englishMonths, layout: "%[2]s %[1]d, %[3]d"},
+	"en-GB": {months: englishMonths, layout: "%[1]d %[2]s %[3]d"},
+	"de-DE": {
+		months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		layout: "%[1]d. %[2]s %[3]d",
+	},
+	"fr-FR": {
+		months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		layout: "%[1]d %[2]s %[3]d",
+	},
+	"ru-RU": {
+		months: [12]string{"января", "февраля", "марта", "апреля", "мая", "июня", "июля", "августа", "сентября", "октября", "ноября", "декабря"},
+		layout: "%[1]d %[2]s %[3]d г.",
+	},
+}
+
+const defaultDateLocale = "en-US" // Прежний формат: "January 2, 2006"
+
+var publishedDateLocale = dateLocales[defaultDateLocale] // Задается -locale
+
+// parseDateLocale находит формат для тега вроде "de-DE" без учета регистра
+// и разницы между "-" и "_". Пустой тег — defaultDateLocale.
+func parseDateLocale(tag string) (dateLocale, error) {
+	if tag == "" {
+		tag = defaultDateLocale
+	}
+	for name, loc := range dateLocales {
+		if strings.EqualFold(name, strings.ReplaceAll(tag, "_", "-")) {
+			return loc, nil
+		}
+	}
+	names := make([]string, 0, len(dateLocales))
+	for name := range dateLocales {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return dateLocale{}, fmt.Errorf("unsupported locale %q, use one of %s", tag, strings.Join(names, ", "))
+}
+
+// FormatPublishedDateIn форматирует дату публикации в формате loc. Для
+// статьи без даты возвращает пустую строку.
+func (a *Article) FormatPublishedDateIn(loc dateLocale) string {
+	if !a.HasPublishedDate() {
+		return ""
+	}
+	year, month, day := a.PublishedAt.Date()
+	return fmt.Sprintf(loc.layout, day, loc.months[month-1], year)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatPublishedDateIn(t *testing.T) {
+	a := Article{PublishedAt: time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"", "March 5, 2024"},
+		{"en-US", "March 5, 2024"},
+		{"en-GB", "5 March 2024"},
+		{"de-DE", "5. März 2024"},
+		{"de_de", "5. März 2024"},
+		{"ru-RU", "5 марта 2024 г."},
+	}
+	for _, tt := range tests {
+		loc, err := parseDateLocale(tt.locale)
+		if err != nil {
+			t.Fatalf("parseDateLocale(%q): %v", tt.locale, err)
+		}
+		if got := a.FormatPublishedDateIn(loc); got != tt.want {
+			t.Errorf("%q: FormatPublishedDateIn = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	var undated Article
+	if got := undated.FormatPublishedDateIn(dateLocales["de-DE"]); got != "" {
+		t.Errorf("zero PublishedAt formatted as %q, want empty", got)
+	}
+	if _, err := parseDateLocale("xx-XX"); err == nil {
+		t.Error("parseDateLocale accepted an unknown locale")
+	}
+}
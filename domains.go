@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// allowedDomains — домены, которыми ограничен сайт; пустой список снимает
+// ограничение. Задается -alloweddomains для тематических копий сайта.
+var allowedDomains []string
+
+// isAllowedDomain сообщает, входит ли домен или хост в allowedDomains, включая
+// поддомены разрешенных доменов. Без ограничения разрешено все.
+func isAllowedDomain(host string) bool {
+	if len(allowedDomains) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, d := range allowedDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictDomains сужает домены запроса до allowedDomains. Запрошенные
+// разрешенные домены остаются; если таких нет, ищем по всему списку:
+// параметр domains не должен расширять выдачу за пределы сайта.
+func restrictDomains(requested string) string {
+	if len(allowedDomains) == 0 {
+		return requested
+	}
+	var domains []string
+	for _, d := range strings.Split(requested, ",") {
+		if d != "" && isAllowedDomain(d) {
+			domains = append(domains, d)
+		}
+	}
+	if len(domains) == 0 {
+		return strings.Join(allowedDomains, ",")
+	}
+	return strings.Join(domains, ",")
+}
+
+// filterAllowedDomains убирает статьи с посторонних доменов: NewsAPI иногда
+// возвращает их даже при заданном domains, а в top-headlines такого
+// параметра нет вовсе.
+func filterAllowedDomains(articles []Article) []Article {
+	if len(allowedDomains) == 0 {
+		return articles
+	}
+	out := articles[:0]
+	for i := range articles {
+		u, err := url.Parse(articles[i].URL)
+		if err == nil && isAllowedDomain(u.Hostname()) {
+			out = append(out, articles[i])
+		}
+	}
+	return out
+}
+
+// HeadlinesFilteredByDomain сообщает, что главные новости сужены до
+// allowedDomains только на этой странице: у top-headlines нет параметра
+// domains, и TotalResults NewsAPI считает по всем источникам. Поиск
+// передает domains в запрос, поэтому его число результатов уже верное.
+func (s *Search) HeadlinesFilteredByDomain() bool {
+	return s.Headlines && len(allowedDomains) > 0
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func setAllowedDomains(t *testing.T, domains ...string) {
+	t.Helper()
+	old := allowedDomains
+	allowedDomains = domains
+	t.Cleanup(func() { allowedDomains = old })
+}
+
+func TestRestrictDomains(t *testing.T) {
+	if got := restrictDomains("example.com"); got != "example.com" {
+		t.Errorf("without allowlist: restrictDomains = %q, want the request unchanged", got)
+	}
+
+	setAllowedDomains(t, "bbc.co.uk", "reuters.com")
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{"", "bbc.co.uk,reuters.com"},
+		{"reuters.com", "reuters.com"},
+		{"news.bbc.co.uk,example.com", "news.bbc.co.uk"},
+		{"example.com", "bbc.co.uk,reuters.com"},
+		{"co.uk", "bbc.co.uk,reuters.com"},
+	}
+	for _, tt := range tests {
+		if got := restrictDomains(tt.requested); got != tt.want {
+			t.Errorf("restrictDomains(%q) = %q, want %q", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestGetNewsEnforcesAllowedDomains(t *testing.T) {
+	var gotDomains string
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		gotDomains = r.URL.Query().Get("domains")
+		w.Write([]byte(`{"status":"ok","totalResults":3,"articles":[
+			{"title":"bbc","url":"https://www.bbc.co.uk/news/1"},
+			{"title":"extra","url":"https://spam.example/1"},
+			{"title":"reuters","url":"https://reuters.com/2"}]}`))
+	})
+	setAllowedDomains(t, "bbc.co.uk", "reuters.com")
+
+	results, err := getNews(context.Background(), newsQuery{Query: "news"}, 20, 1)
+	if err != nil {
+		t.Fatalf("getNews: %v", err)
+	}
+	if gotDomains != "bbc.co.uk,reuters.com" {
+		t.Errorf("NewsAPI domains = %q, want the allowlist", gotDomains)
+	}
+	if got := titles(results.Articles); !reflect.DeepEqual(got, []string{"bbc", "reuters"}) {
+		t.Errorf("articles = %v, want only allowed domains", got)
+	}
+}
+
+func TestHeadlinesCountWithAllowedDomains(t *testing.T) {
+	useIndexTemplate(t)
+	search := &Search{
+		Headlines:   true,
+		CurrentPage: 1,
+		TotalPages:  3,
+		Locale:      defaultLocale,
+		Results: Results{TotalResults: 60, Articles: []Article{
+			{Title: "Local story", URL: "https://news.example/1"},
+		}},
+	}
+	render := func() string {
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, search); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		return buf.String()
+	}
+
+	if body := render(); !strings.Contains(body, "About <strong>60</strong> results") {
+		t.Errorf("without -alloweddomains the upstream total is not shown:\n%s", body)
+	}
+	setAllowedDomains(t, "news.example")
+	if body := render(); !strings.Contains(body, "<strong>1</strong> headlines from this site's sources") || strings.Contains(body, "<strong>60</strong>") {
+		t.Errorf("with -alloweddomains the count does not say it is per page:\n%s", body)
+	}
+}
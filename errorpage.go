@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ErrorPage — данные для error.html.
+type ErrorPage struct {
+	*Search
+	Status  int
+	Message string
+}
+
+// TitleKey — ключ перевода заголовка страницы ошибки по ее коду.
+func (p ErrorPage) TitleKey() string {
+	switch p.Status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return "error.unavailable.title"
+	case http.StatusNotFound:
+		return "notfound.title"
+	case http.StatusUnauthorized, http.StatusInternalServerError:
+		return "error.title"
+	}
+	if p.Status < 500 {
+		return "error.request.title"
+	}
+	return "error.title"
+}
+
+// renderError отдает error.html в оформлении сайта с кодом status и
+// сообщением msg. Если сам шаблон ошибки не отрисовался, отвечает обычным
+// текстом, как http.Error.
+func renderError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	page := ErrorPage{
+		Search: &Search{
+			ReaderMode: readerMode(r),
+			Theme:      requestTheme(r),
+			Locale:     requestLocale(r),
+		},
+		Status:  status,
+		Message: msg,
+	}
+
+	var buf bytes.Buffer
+	if err := templates().Error.Execute(&buf, page); err != nil {
+		log.Printf("Error executing error template: %v", err)
+		http.Error(w, msg, status)
+		return
+	}
+
+	h := w.Header()
+	h.Del("Content-Length") // Мог остаться от http.Error, перехваченного errorPageWriter
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// errorPageWriter переписывает ответы http.Error в страницу error.html, как
+// jsonErrorWriter — в JSON. Остальные ответы (редиректы, 304) проходят как есть.
+type errorPageWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	status int
+	body   bytes.Buffer
+}
+
+func (e *errorPageWriter) WriteHeader(status int) {
+	if status < 400 {
+		e.ResponseWriter.WriteHeader(status)
+		return
+	}
+	e.status = status
+}
+
+func (e *errorPageWriter) Write(b []byte) (int, error) {
+	if e.status == 0 {
+		return e.ResponseWriter.Write(b)
+	}
+	return e.body.Write(b)
+}
+
+// finish отправляет накопленную ошибку, если она была.
+func (e *errorPageWriter) finish() {
+	if e.status != 0 {
+		renderError(e.ResponseWriter, e.r, e.status, strings.TrimSpace(e.body.String()))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearchHandlerErrorPage(t *testing.T) {
+	tests := []struct {
+		target    string
+		apiStatus int
+		status    int
+		want      []string
+	}{
+		{"/search?q=golang&page=abc", http.StatusOK, http.StatusBadRequest, []string{"Error 400", "We could not handle this request", "Invalid page number"}},
+		{"/search?q=golang", http.StatusInternalServerError, http.StatusBadGateway, []string{"Error 502", "The news service is unavailable", "News service is unavailable"}},
+		{"/search?q=golang&ui=ru", http.StatusTooManyRequests, http.StatusTooManyRequests, []string{"Ошибка 429", "Новостной сервис недоступен"}},
+	}
+	for _, tt := range tests {
+		newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.apiStatus)
+			fmt.Fprint(w, articlesJSON(45, 20))
+		})
+
+		rec := httptest.NewRecorder()
+		searchHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Fatalf("%s: status = %d, want %d; body: %s", tt.target, rec.Code, tt.status, rec.Body)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("%s: Content-Type = %q, want text/html", tt.target, ct)
+		}
+		body := rec.Body.String()
+		for _, want := range append(tt.want, `class="container message-page"`) {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: body does not contain %q:\n%s", tt.target, want, body)
+			}
+		}
+	}
+}
+
+func TestRenderErrorFallback(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	old := errorTpl
+	errorTpl = template.Must(template.New("error.html").Parse(`{{ .Missing }}`))
+	t.Cleanup(func() { errorTpl = old })
+
+	rec := httptest.NewRecorder()
+	renderError(rec, httptest.NewRequest(http.MethodGet, "/search", nil), http.StatusBadRequest, "Invalid page number")
+	if rec.Code != http.StatusBadRequest || strings.TrimSpace(rec.Body.String()) != "Invalid page number" {
+		t.Errorf("fallback = %d %q, want 400 plain text", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("fallback Content-Type = %q, want text/plain", ct)
+	}
+}
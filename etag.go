@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// renderedPage — отрисованная страница результатов вместе с ее ETag и
+// записью аудита поиска, по которому она построена.
+type renderedPage struct {
+	body  []byte
+	etag  string
+	audit searchAudit
+}
+
+// pageETag строит слабый ETag страницы результатов из параметров поиска,
+// варианта отображения, версии шаблонов и списка статей. Вместо времени
+// прошлого визита учитываются сами отметки "новое", поэтому обновление
+// страницы без новых статей дает тот же ETag.
+func pageETag(r *http.Request, s *Search, templatesVersion int64) string {
+	params, _ := searchParams(r)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s?%s|locale=%s|reader=%t|theme=%s|secure=%t|templates=%d|recent=%q", r.URL.Path, params.Encode(), s.Locale, s.ReaderMode, s.Theme, s.Secure, templatesVersion, s.RecentSearches)
+	for _, a := range s.Results.Articles {
+		fmt.Fprintf(h, "|%s|new=%t|pinned=%t|saved=%t", a.URL, a.NewSinceVisit, a.Pinned, s.IsBookmarked(a.URL))
+	}
+	for _, a := range s.Related {
+		fmt.Fprintf(h, "|related=%s", a.URL)
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:12]) + `"`
+}
+
+// etagMatches сообщает, совпадает ли etag с одним из значений If-None-Match.
+// Сравнение слабое, как того требует RFC 9110 для If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// SourceFacet — сколько статей текущей выдачи пришло из одного источника.
+type SourceFacet struct {
+	Name   string // Пустое — статьи без имени источника ("Other")
+	Domain string // Домен для фильтра domains; пустой, если его не узнать
+	Count  int
+}
+
+// SourceFacets считает статьи по именам источников, от самых частых к
+// редким, а при равенстве — по имени. Статьи без имени источника собираются
+// в одну группу с пустым Name, она всегда последняя.
+func (r Results) SourceFacets() []SourceFacet {
+	index := map[string]int{}
+	var facets []SourceFacet
+	for _, a := range r.Articles {
+		name := strings.TrimSpace(a.Source.Name)
+		i, ok := index[name]
+		if !ok {
+			i = len(facets)
+			index[name] = i
+			facets = append(facets, SourceFacet{Name: name})
+		}
+		facets[i].Count++
+		if facets[i].Domain == "" && name != "" {
+			facets[i].Domain = articleDomain(a.URL)
+		}
+	}
+
+	sort.SliceStable(facets, func(i, j int) bool {
+		a, b := facets[i], facets[j]
+		if (a.Name == "") != (b.Name == "") {
+			return b.Name == ""
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	return facets
+}
+
+// articleDomain — хост адреса статьи без "www." или пустая строка, если
+// адрес не разбирается или хост не годится для параметра domains.
+func articleDomain(articleURL string) string {
+	u, err := url.Parse(articleURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if !hostnamePattern.MatchString(host) {
+		return ""
+	}
+	return host
+}
+
+// FacetURL — адрес первой страницы текущего поиска, ограниченного доменом
+// источника.
+func (s *Search) FacetURL(domain string) string {
+	v := s.query(1)
+	v.Set("domains", domain)
+	v.Del("page")
+	return "/search?" + v.Encode()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSourceFacets(t *testing.T) {
+	results := Results{Articles: []Article{
+		{Source: Source{Name: "Reuters"}, URL: "https://www.reuters.com/1"},
+		{Source: Source{Name: ""}, URL: "https://blog.example/1"},
+		{Source: Source{Name: "BBC News"}, URL: "https://www.bbc.co.uk/1"},
+		{Source: Source{Name: "Reuters"}, URL: "https://www.reuters.com/2"},
+		{Source: Source{Name: "AP"}, URL: "not a url"},
+		{Source: Source{Name: " "}, URL: "https://other.example/1"},
+	}}
+
+	want := []SourceFacet{
+		{Name: "Reuters", Domain: "reuters.com", Count: 2},
+		{Name: "AP", Count: 1},
+		{Name: "BBC News", Domain: "bbc.co.uk", Count: 1},
+		{Name: "", Count: 2},
+	}
+	if got := results.SourceFacets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SourceFacets = %+v, want %+v", got, want)
+	}
+	if got := (Results{}).SourceFacets(); len(got) != 0 {
+		t.Errorf("SourceFacets for no articles = %+v, want none", got)
+	}
+}
+
+func TestSearchPageFacets(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ok","totalResults":2,"articles":[
+			{"source":{"id":null,"name":"BBC News"},"title":"one","url":"https://www.bbc.co.uk/1"},
+			{"source":{"id":null,"name":""},"title":"two","url":"https://blog.example/2"}]}`)
+	})
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=news&sortBy=popularity&page=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{`href="/search?domains=bbc.co.uk&amp;q=news&amp;sortBy=popularity"`, "Other"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
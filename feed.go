@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// rssFeed — документ RSS 2.0 для /feed.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link,omitempty"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description,omitempty"`
+	Author      string  `xml:"author,omitempty"`
+	PubDate     string  `xml:"pubDate,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// feedHandler отдает первую страницу результатов поиска по q в виде RSS 2.0,
+// чтобы на сохраненный поиск можно было подписаться в читалке.
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := sanitizeQuery(r.URL.Query().Get("q"))
+	if err == nil {
+		query, err = normalizeQuery(query)
+	}
+	if err != nil {
+		http.Error(w, queryErrorMessage(err), http.StatusBadRequest)
+		return
+	}
+	if query == "" {
+		http.Error(w, "Query is required", http.StatusBadRequest)
+		return
+	}
+
+	results, err := searchNews(r.Context(), newsQuery{Query: query}, defaultPageSize, 1)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Client went away before news arrived: %v", err)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting news: %v", err)
+		status, msg := newsErrorStatus(w, err)
+		http.Error(w, msg, status)
+		return
+	}
+	results.dedupe()
+	articles := transformArticles(results.Articles, exportWorkers, cleanArticle)
+
+	body, err := xml.MarshalIndent(newRSSFeed(query, siteURL(r), articles, time.Now()), "", "  ")
+	if err != nil {
+		log.Printf("Error encoding feed: %v", err)
+		http.Error(w, "Failed to encode feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// newRSSFeed собирает канал по статьям; site — адрес сайта без завершающего
+// слэша, на него ведет ссылка канала. Без site ссылки нет: относительная
+// ссылка в RSS 2.0 недопустима.
+func newRSSFeed(query, site string, articles []Article, now time.Time) rssFeed {
+	items := make([]rssItem, 0, len(articles))
+	for _, a := range articles {
+		item := rssItem{
+			Title:       a.Title,
+			Link:        a.URL,
+			Description: a.Description,
+			Author:      a.Author,
+			GUID:        rssGUID{IsPermaLink: true, Value: a.URL},
+		}
+		if a.HasPublishedDate() {
+			item.PubDate = a.PublishedAt.Format(time.RFC1123Z)
+		}
+		items = append(items, item)
+	}
+
+	var link string
+	if site != "" {
+		link = site + "/search?" + url.Values{"q": {query}}.Encode()
+	}
+	return rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         "News search: " + query,
+			Link:          link,
+			Description:   "Latest NewsAPI articles matching " + query,
+			LastBuildDate: now.Format(time.RFC1123Z),
+			Items:         items,
+		},
+	}
+}
+
+// siteURL возвращает адрес сайта без завершающего слэша для абсолютных ссылок.
+// Это -siteurl; заголовку Host доверять нельзя, поэтому адрес по запросу
+// собирается только в режиме -dev. Пустая строка — адрес неизвестен.
+func siteURL(r *http.Request) string {
+	if publicSiteURL != "" || !devMode {
+		return publicSiteURL
+	}
+	scheme := "http"
+	if isSecureRequest(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFeedHandler(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"ok","totalResults":1,"articles":[{"source":{"name":"Source"},"title":"<b>Mars</b> &amp; more","description":"<p>Rover news</p>","url":"https://example.com/mars?id=1&utm_source=newsapi","publishedAt":"2024-05-01T10:00:00Z"}]}`)
+	})
+	oldSite, oldDev := publicSiteURL, devMode
+	defer func() { publicSiteURL, devMode = oldSite, oldDev }()
+	devMode = false
+
+	feed := func() rssFeed {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		feedHandler(rec, httptest.NewRequest(http.MethodGet, "http://evil.example/feed?q=mars", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body: %s", rec.Code, rec.Body)
+		}
+		var f rssFeed
+		if err := xml.Unmarshal(rec.Body.Bytes(), &f); err != nil {
+			t.Fatalf("feed XML: %v\n%s", err, rec.Body)
+		}
+		return f
+	}
+
+	publicSiteURL = "https://news.example.com"
+	f := feed()
+	if want := "https://news.example.com/search?q=mars"; f.Channel.Link != want {
+		t.Errorf("channel link = %q, want %q", f.Channel.Link, want)
+	}
+	if len(f.Channel.Items) != 1 {
+		t.Fatalf("items = %+v, want one", f.Channel.Items)
+	}
+	item := f.Channel.Items[0]
+	if item.Title != "Mars & more" || item.Description != "Rover news" {
+		t.Errorf("item = %q / %q, want text without HTML", item.Title, item.Description)
+	}
+	if want := "https://example.com/mars?id=1"; item.Link != want || item.GUID.Value != want {
+		t.Errorf("item link = %q, guid = %q; want %q without tracking parameters", item.Link, item.GUID.Value, want)
+	}
+
+	publicSiteURL = ""
+	if f := feed(); f.Channel.Link != "" {
+		t.Errorf("channel link without -siteurl = %q, want no link element", f.Channel.Link)
+	}
+}
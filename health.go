@@ -0,0 +1,40 @@
+package main
+
+import "net/http"
+
+// healthStatus — тело ответов /healthz и /readyz.
+type healthStatus struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// healthzHandler — проверка живости: процесс запущен и отвечает.
+// Не зависит ни от NewsAPI, ни от ключа API.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// readyzHandler — проверка готовности: шаблоны разобраны и ключ API задан.
+// NewsAPI при этом не опрашивается.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if reason := notReadyReason(); reason != "" {
+		writeJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "not ready", Reason: reason})
+		return
+	}
+	writeJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
+
+// notReadyReason возвращает причину неготовности или пустую строку.
+func notReadyReason() string {
+	templatesMu.RLock()
+	loaded := tpl != nil && notFoundTpl != nil && articleTpl != nil && errorTpl != nil && maintenanceTpl != nil
+	templatesMu.RUnlock()
+
+	switch {
+	case !loaded:
+		return "templates are not loaded"
+	case apiKeys == nil || apiKeys.Len() == 0:
+		return "API key is not set"
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyEntry — один запрос в истории поиска.
+type historyEntry struct {
+	Query    string
+	Count    int
+	LastSeen time.Time
+}
+
+// searchHistory хранит недавние поисковые запросы с ограничением по числу
+// записей (вытесняется самый давний) и по времени жизни записи.
+type searchHistory struct {
+	mu      sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	order   *list.List // В начале списка — самые свежие запросы
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+func newSearchHistory(maxSize int, ttl time.Duration) *searchHistory {
+	return &searchHistory{
+		maxSize: maxSize,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+// Add записывает запрос в историю. Запросы сравниваются без учета регистра.
+func (h *searchHistory) Add(query string) {
+	key := strings.ToLower(strings.TrimSpace(query))
+	if key == "" {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	h.expire(now)
+
+	if el, ok := h.entries[key]; ok {
+		entry := el.Value.(*historyEntry)
+		entry.Count++
+		entry.LastSeen = now
+		h.order.MoveToFront(el)
+		return
+	}
+
+	h.entries[key] = h.order.PushFront(&historyEntry{Query: key, Count: 1, LastSeen: now})
+	for h.maxSize > 0 && h.order.Len() > h.maxSize {
+		h.remove(h.order.Back())
+	}
+}
+
+// Entries возвращает сохраненные и еще не устаревшие запросы, начиная с самых свежих.
+func (h *searchHistory) Entries() []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire(h.now())
+
+	entries := make([]historyEntry, 0, h.order.Len())
+	for el := h.order.Front(); el != nil; el = el.Next() {
+		entries = append(entries, *el.Value.(*historyEntry))
+	}
+	return entries
+}
+
+// expire удаляет записи старше ttl. Вызывается под h.mu.
+func (h *searchHistory) expire(now time.Time) {
+	if h.ttl <= 0 {
+		return
+	}
+	for el := h.order.Back(); el != nil; el = h.order.Back() {
+		if now.Sub(el.Value.(*historyEntry).LastSeen) < h.ttl {
+			return
+		}
+		h.remove(el)
+	}
+}
+
+func (h *searchHistory) remove(el *list.Element) {
+	h.order.Remove(el)
+	delete(h.entries, el.Value.(*historyEntry).Query)
+}
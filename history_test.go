@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// queries возвращает запросы из истории в порядке Entries.
+func queries(h *searchHistory) []string {
+	var out []string
+	for _, e := range h.Entries() {
+		out = append(out, e.Query)
+	}
+	return out
+}
+
+func TestSearchHistoryEvictsOldest(t *testing.T) {
+	h := newSearchHistory(2, 0)
+	h.Add("go")
+	h.Add("rust")
+	h.Add("Go ") // Тот же запрос без учета регистра поднимается наверх
+	h.Add("zig")
+
+	got := queries(h)
+	if len(got) != 2 || got[0] != "zig" || got[1] != "go" {
+		t.Fatalf("Entries = %v, want [zig go]", got)
+	}
+	if e := h.Entries()[1]; e.Count != 2 {
+		t.Errorf("go count = %d, want 2", e.Count)
+	}
+}
+
+func TestSearchHistoryExpires(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	h := newSearchHistory(10, time.Hour)
+	h.now = func() time.Time { return now }
+
+	h.Add("old")
+	now = now.Add(30 * time.Minute)
+	h.Add("fresh")
+	now = now.Add(45 * time.Minute) // "old" прожил 75 минут, "fresh" — 45
+
+	if got := queries(h); len(got) != 1 || got[0] != "fresh" {
+		t.Fatalf("Entries = %v, want [fresh]", got)
+	}
+	now = now.Add(time.Hour)
+	if got := queries(h); len(got) != 0 {
+		t.Errorf("Entries = %v after the TTL, want none", got)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var homeRefresh time.Duration // Как часто обновлять главные новости на главной; 0 — главная без новостей
+
+var homeRetryMin = 10 * time.Second // Первая пауза после ошибки NewsAPI; дальше удваивается до homeRefresh
+
+// homeHeadlines — последние главные новости для главной страницы. Их
+// обновляет refresh в фоне, а renderHome только читает.
+type homeHeadlines struct {
+	mu       sync.RWMutex
+	articles []Article
+	loaded   bool // Хотя бы одна загрузка удалась
+}
+
+var home homeHeadlines
+
+// get возвращает новости и false, если первая загрузка еще не удалась.
+func (h *homeHeadlines) get() ([]Article, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.articles, h.loaded
+}
+
+func (h *homeHeadlines) set(articles []Article) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.articles, h.loaded = articles, true
+}
+
+// refresh загружает новости через fetch сразу и затем каждые interval, пока
+// не отменят ctx. После ошибки пробует раньше: через homeRetryMin и дальше с
+// удвоением паузы до interval. Пока новые не пришли, остаются прежние.
+func (h *homeHeadlines) refresh(ctx context.Context, interval time.Duration, fetch func(context.Context) (Results, error)) {
+	var backoff time.Duration
+	for {
+		results, err := fetch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		wait := interval
+		if err != nil {
+			backoff = min(max(backoff*2, homeRetryMin), interval)
+			wait = backoff
+			slog.Warn("Error refreshing homepage headlines", "error", err, "retry_in", wait)
+		} else {
+			backoff = 0
+			h.set(results.Articles)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// fetchHomeHeadlines — главные новости для главной: страна по умолчанию, без
+// категории, первая страница.
+func fetchHomeHeadlines(ctx context.Context) (Results, error) {
+	return getTopHeadlines(ctx, "", defaultCountry, defaultPageSize, 1)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHomeHeadlinesRefresh(t *testing.T) {
+	oldRetry := homeRetryMin
+	homeRetryMin = time.Millisecond
+	t.Cleanup(func() { homeRetryMin = oldRetry })
+
+	calls := make(chan int, 10)
+	n := 0
+	fetch := func(ctx context.Context) (Results, error) {
+		n++
+		calls <- n
+		if n == 1 {
+			return Results{}, errors.New("upstream down")
+		}
+		return Results{Articles: []Article{{Title: fmt.Sprintf("headline %d", n)}}}, nil
+	}
+
+	var h homeHeadlines
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.refresh(ctx, time.Hour, fetch) // Второй вызов возможен только по паузе после ошибки
+	}()
+
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-calls:
+			if got != want {
+				t.Fatalf("fetch call %d, want %d", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("fetch call %d did not happen; the retry after an error should not wait for the interval", want)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresh did not stop after cancel")
+	}
+	articles, loaded := h.get()
+	if !loaded || len(articles) != 1 || articles[0].Title != "headline 2" {
+		t.Errorf("get = %v, %t; want the second fetch", articles, loaded)
+	}
+}
+
+func TestRenderHomeHeadlines(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	oldRefresh, oldHome := homeRefresh, home.articles
+	homeRefresh = time.Minute
+	t.Cleanup(func() {
+		homeRefresh = oldRefresh
+		home.articles, home.loaded = oldHome, oldHome != nil
+	})
+
+	home.articles, home.loaded = nil, false
+	rec := httptest.NewRecorder()
+	indexHandler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `class="home-loading"`) {
+		t.Errorf("homepage before the first refresh should show the loading state:\n%s", rec.Body)
+	}
+
+	home.set([]Article{{Title: "Cached headline", URL: "https://example.com/1"}})
+	rec = httptest.NewRecorder()
+	indexHandler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "Cached headline") || strings.Contains(body, `class="home-loading"`) {
+		t.Errorf("homepage should list cached headlines without the loading state:\n%s", body)
+	}
+}
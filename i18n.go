@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultLocale = "en"
+
+// messages — каталог строк интерфейса по локалям. Строки могут содержать
+// разметку; подставляемые строковые аргументы экранируются в translate.
+var messages = map[string]map[string]string{
+	"en": {
+		"search.placeholder":         "Enter a news topic",
+		"github":                     "View on Github",
+		"reader.on":                  "Reader mode",
+		"reader.off":                 "Standard view",
+		"results.count":              "About <strong>%d</strong> results were found. You are on page <strong>%d</strong> of <strong>%d</strong>.",
+		"results.none":               "No results found for your query: <strong>%s</strong>.",
+		"export":                     "Export as JSON",
+		"page.previous":              "Previous",
+		"page.next":                  "Next",
+		"pinned":                     "Pinned",
+		"new":                        "New since your last visit",
+		"verified":                   "Verified",
+		"headlines":                  "Top headlines",
+		"category.business":          "Business",
+		"category.entertainment":     "Entertainment",
+		"category.general":           "General",
+		"category.health":            "Health",
+		"category.science":           "Science",
+		"category.sports":            "Sports",
+		"category.technology":        "Technology",
+		"sort.relevancy":             "Most relevant",
+		"sort.popularity":            "Most popular",
+		"sort.publishedAt":           "Newest first",
+		"range.from":                 "Published from <strong>%s</strong>.",
+		"range.to":                   "Published until <strong>%s</strong>.",
+		"range.between":              "Published from <strong>%s</strong> to <strong>%s</strong>.",
+		"notfound.title":             "Page not found",
+		"notfound.text":              "The page you are looking for does not exist or has moved.",
+		"notfound.home":              "Back to the homepage",
+		"feed":                       "RSS feed",
+		"results.none.headlines":     "No headlines found in this category right now.",
+		"tips.title":                 "You could try:",
+		"tips.fewer":                 "Using fewer or more general words.",
+		"tips.operators":             "Removing quotes, AND / OR / NOT operators or domain filters.",
+		"tips.dates":                 "Widening or clearing the date range.",
+		"tips.trusted":               "Showing all sources, not only verified ones.",
+		"tips.language":              "Searching in another language.",
+		"article.read":               "Read here",
+		"article.original":           "Read the original at %s",
+		"article.fallback":           "We could not load the full article, so this is the summary provided by NewsAPI.",
+		"results.author":             "<strong>%d</strong> articles by <strong>%s</strong> on page <strong>%d</strong> of <strong>%d</strong>. The author filter only covers the results on this page.",
+		"results.trusted":            "<strong>%d</strong> articles from verified sources on page <strong>%d</strong> of <strong>%d</strong>. Other sources are only hidden on this page.",
+		"results.alloweddomains":     "<strong>%d</strong> headlines from this site's sources on page <strong>%d</strong> of <strong>%d</strong>. Other sources are only hidden on this page.",
+		"export.csv":                 "Export as CSV",
+		"country":                    "Country",
+		"recent":                     "Recent:",
+		"search.prompt":              "Please enter a search term.",
+		"meta.home":                  "Search the latest news from thousands of sources worldwide.",
+		"meta.search.title":          "News about %s",
+		"meta.search.description":    "About %d news articles matching %s.",
+		"meta.headlines.title":       "Top headlines",
+		"meta.category.title":        "Top headlines: %s",
+		"meta.headlines.description": "%d top stories right now.",
+		"search.syntax":              "Use \"quotes\" for exact phrases, AND / OR / NOT between words, +word to require and -word to exclude",
+		"error.title":                "Something went wrong",
+		"error.text":                 "An unexpected error occurred on our side. Please try again in a moment.",
+		"results.maxage":             "<strong>%d</strong> articles from the last <strong>%d</strong> days on page <strong>%d</strong> of <strong>%d</strong>. Older articles are only hidden on this page.",
+		"theme":                      "Theme",
+		"theme.auto":                 "System theme",
+		"theme.light":                "Light",
+		"theme.dark":                 "Dark",
+		"related":                    "Related stories",
+		"bookmarks":                  "Read later",
+		"bookmarks.title":            "Saved articles",
+		"bookmarks.count":            "<strong>%d</strong> saved articles. They are stored in this browser only.",
+		"bookmarks.none":             "You have not saved any articles yet.",
+		"bookmark.add":               "Save",
+		"bookmark.remove":            "Remove from saved",
+		"results.limited":            "Free plan limits results to the first %d; refine your search to reach the rest.",
+		"date.unknown":               "Date unknown",
+		"error.status":               "Error %d",
+		"error.request.title":        "We could not handle this request",
+		"error.unavailable.title":    "The news service is unavailable",
+		"maintenance.title":          "Down for maintenance",
+		"maintenance.text":           "We are doing some work on the site. Please check back in a few minutes.",
+		"facets":                     "Sources on this page",
+		"facets.other":               "Other",
+		"home.headlines":             "Top headlines",
+		"home.loading":               "Top headlines are loading, refresh the page in a moment.",
+		"home.more":                  "All top headlines",
+		"ago.now":                    "just now",
+		"ago.second.one":             "%d second ago",
+		"ago.second.other":           "%d seconds ago",
+		"ago.minute.one":             "%d minute ago",
+		"ago.minute.other":           "%d minutes ago",
+		"ago.hour.one":               "%d hour ago",
+		"ago.hour.other":             "%d hours ago",
+		"ago.day.one":                "%d day ago",
+		"ago.day.other":              "%d days ago",
+	},
+	"ru": {
+		"search.placeholder":         "Введите тему новостей",
+		"github":                     "Открыть на Github",
+		"reader.on":                  "Режим чтения",
+		"reader.off":                 "Обычный вид",
+		"results.count":              "Найдено примерно <strong>%d</strong> результатов. Вы на странице <strong>%d</strong> из <strong>%d</strong>.",
+		"results.none":               "По запросу <strong>%s</strong> ничего не найдено.",
+		"export":                     "Экспорт в JSON",
+		"page.previous":              "Назад",
+		"page.next":                  "Вперед",
+		"pinned":                     "Закреплено",
+		"new":                        "Новое с прошлого визита",
+		"verified":                   "Проверенный источник",
+		"headlines":                  "Главное",
+		"category.business":          "Бизнес",
+		"category.entertainment":     "Развлечения",
+		"category.general":           "Общее",
+		"category.health":            "Здоровье",
+		"category.science":           "Наука",
+		"category.sports":            "Спорт",
+		"category.technology":        "Технологии",
+		"sort.relevancy":             "Сначала релевантные",
+		"sort.popularity":            "Сначала популярные",
+		"sort.publishedAt":           "Сначала новые",
+		"range.from":                 "Опубликовано с <strong>%s</strong>.",
+		"range.to":                   "Опубликовано по <strong>%s</strong>.",
+		"range.between":              "Опубликовано с <strong>%s</strong> по <strong>%s</strong>.",
+		"notfound.title":             "Страница не найдена",
+		"notfound.text":              "Такой страницы нет или она была перемещена.",
+		"notfound.home":              "На главную",
+		"feed":                       "RSS-лента",
+		"results.none.headlines":     "Сейчас в этой категории нет главных новостей.",
+		"tips.title":                 "Что можно попробовать:",
+		"tips.fewer":                 "Меньше слов или более общие слова.",
+		"tips.operators":             "Убрать кавычки, операторы AND / OR / NOT или фильтры по доменам.",
+		"tips.dates":                 "Расширить или убрать диапазон дат.",
+		"tips.language":              "Искать на другом языке.",
+		"tips.trusted":               "Показать все источники, а не только проверенные.",
+		"article.read":               "Читать здесь",
+		"article.original":           "Оригинал на %s",
+		"article.fallback":           "Не удалось загрузить статью целиком, поэтому показан фрагмент из NewsAPI.",
+		"results.author":             "Статей автора <strong>%[2]s</strong> на странице <strong>%[3]d</strong> из <strong>%[4]d</strong>: <strong>%[1]d</strong>. Фильтр по автору действует только в пределах этой страницы.",
+		"results.trusted":            "Статей из проверенных источников на странице <strong>%[2]d</strong> из <strong>%[3]d</strong>: <strong>%[1]d</strong>. Остальные источники скрыты только на этой странице.",
+		"results.alloweddomains":     "Главных новостей из источников сайта на странице <strong>%[2]d</strong> из <strong>%[3]d</strong>: <strong>%[1]d</strong>. Остальные источники скрыты только на этой странице.",
+		"export.csv":                 "Экспорт в CSV",
+		"country":                    "Страна",
+		"recent":                     "Недавние:",
+		"search.prompt":              "Введите, что искать.",
+		"meta.home":                  "Свежие новости из тысяч источников по всему миру.",
+		"meta.search.title":          "Новости: %s",
+		"meta.search.description":    "Статей по запросу «%[2]s»: примерно %[1]d.",
+		"meta.headlines.title":       "Главное",
+		"meta.category.title":        "Главное: %s",
+		"meta.headlines.description": "Главных новостей сейчас: %d.",
+		"search.syntax":              "Фраза целиком — в \"кавычках\", между словами — AND / OR / NOT, +слово — обязательно, -слово — исключить",
+		"error.title":                "Что-то пошло не так",
+		"error.text":                 "На сервере произошла непредвиденная ошибка. Попробуйте еще раз чуть позже.",
+		"results.maxage":             "Статей за последние <strong>%[2]d</strong> дн. на странице <strong>%[3]d</strong> из <strong>%[4]d</strong>: <strong>%[1]d</strong>. Более старые статьи скрыты только на этой странице.",
+		"theme":                      "Тема",
+		"theme.auto":                 "Как в системе",
+		"theme.light":                "Светлая",
+		"theme.dark":                 "Темная",
+		"related":                    "Похожие новости",
+		"bookmarks":                  "Прочитать позже",
+		"bookmarks.title":            "Сохраненные статьи",
+		"bookmarks.count":            "Сохранено статей: <strong>%d</strong>. Они хранятся только в этом браузере.",
+		"bookmarks.none":             "Вы еще не сохранили ни одной статьи.",
+		"bookmark.add":               "Сохранить",
+		"bookmark.remove":            "Убрать из сохраненных",
+		"results.limited":            "Бесплатный тариф показывает только первые %d результатов; уточните запрос, чтобы найти остальные.",
+		"date.unknown":               "Дата неизвестна",
+		"error.status":               "Ошибка %d",
+		"error.request.title":        "Не удалось обработать запрос",
+		"error.unavailable.title":    "Новостной сервис недоступен",
+		"maintenance.title":          "Сайт на обслуживании",
+		"maintenance.text":           "Мы проводим работы на сайте. Загляните через несколько минут.",
+		"facets":                     "Источники на этой странице",
+		"facets.other":               "Другие",
+		"home.headlines":             "Главные новости",
+		"home.loading":               "Главные новости загружаются, обновите страницу чуть позже.",
+		"home.more":                  "Все главные новости",
+		"ago.now":                    "только что",
+		"ago.second.one":             "%d секунду назад",
+		"ago.second.few":             "%d секунды назад",
+		"ago.second.many":            "%d секунд назад",
+		"ago.minute.one":             "%d минуту назад",
+		"ago.minute.few":             "%d минуты назад",
+		"ago.minute.many":            "%d минут назад",
+		"ago.hour.one":               "%d час назад",
+		"ago.hour.few":               "%d часа назад",
+		"ago.hour.many":              "%d часов назад",
+		"ago.day.one":                "%d день назад",
+		"ago.day.few":                "%d дня назад",
+		"ago.day.many":               "%d дней назад",
+	},
+}
+
+// translate возвращает строку key для локали, используя английский вариант,
+// если перевода нет, и сам ключ, если строки нет совсем.
+func translate(locale, key string, args ...interface{}) template.HTML {
+	msg, ok := messages[locale][key]
+	if !ok {
+		msg, ok = messages[defaultLocale][key]
+	}
+	if !ok {
+		return template.HTML(template.HTMLEscapeString(key))
+	}
+	if len(args) == 0 {
+		return template.HTML(msg)
+	}
+
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		if str, isString := arg.(string); isString {
+			escaped[i] = template.HTMLEscapeString(str)
+		} else {
+			escaped[i] = arg
+		}
+	}
+	return template.HTML(fmt.Sprintf(msg, escaped...))
+}
+
+// requestLocale выбирает локаль по параметру ui (из URL или формы), затем по заголовку
+// Accept-Language. Неизвестные локали заменяются на defaultLocale. Параметр
+// lang — фильтр языка статей, на интерфейс он не влияет.
+func requestLocale(r *http.Request) string {
+	if lang := supportedLocale(r.FormValue("ui")); lang != "" {
+		return lang
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		if lang := supportedLocale(tag); lang != "" {
+			return lang
+		}
+	}
+	return defaultLocale
+}
+
+// supportedLocale приводит языковой тег вроде "ru-RU" к локали каталога
+// или возвращает пустую строку, если такой локали нет.
+func supportedLocale(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	lang = strings.ToLower(lang)
+	if _, ok := messages[lang]; ok {
+		return lang
+	}
+	return ""
+}
+
+// TimeAgo возвращает относительное время публикации на языке locale вроде
+// "3 hours ago". Статьи старше 30 дней показываются абсолютной датой, а дата
+// из будущего (расхождение часов) — как "just now".
+func (a *Article) TimeAgo(locale string) string {
+	if !a.HasPublishedDate() {
+		return a.FormatPublishedDate()
+	}
+
+	d := clock().Sub(a.PublishedAt)
+	switch {
+	case d < time.Second:
+		return string(translate(locale, "ago.now"))
+	case d < time.Minute:
+		return pluralAgo(locale, int(d/time.Second), "second")
+	case d < time.Hour:
+		return pluralAgo(locale, int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return pluralAgo(locale, int(d/time.Hour), "hour")
+	case d < 30*24*time.Hour:
+		return pluralAgo(locale, int(d/(24*time.Hour)), "day")
+	}
+	return a.FormatPublishedDate()
+}
+
+// pluralAgo — "n unit ago" в нужной для n форме слова, см. pluralForm.
+func pluralAgo(locale string, n int, unit string) string {
+	return string(translate(locale, "ago."+unit+"."+pluralForm(locale, n), n))
+}
+
+// pluralForm — форма слова для числа n: в английском one и other, в русском
+// one (1, 21), few (2–4, 22–24) и many (остальные, включая 11–14).
+func pluralForm(locale string, n int) string {
+	if locale != "ru" {
+		if n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "one"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "few"
+	}
+	return "many"
+}
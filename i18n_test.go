@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranslate(t *testing.T) {
+	messages["en"]["test.greeting"] = "Hello, <b>%s</b>"
+	messages["ru"]["test.greeting"] = "Привет, <b>%s</b>"
+	messages["en"]["test.english-only"] = "Only in English"
+	defer func() {
+		delete(messages["en"], "test.greeting")
+		delete(messages["ru"], "test.greeting")
+		delete(messages["en"], "test.english-only")
+	}()
+
+	tests := []struct {
+		locale, key string
+		args        []interface{}
+		want        template.HTML
+	}{
+		{"en", "test.greeting", []interface{}{"Ann"}, "Hello, <b>Ann</b>"},
+		{"ru", "test.greeting", []interface{}{"Ann"}, "Привет, <b>Ann</b>"},
+		{"ru", "test.greeting", []interface{}{"<script>"}, "Привет, <b>&lt;script&gt;</b>"},
+		{"ru", "test.english-only", nil, "Only in English"},   // Нет перевода — английский вариант
+		{"de", "test.english-only", nil, "Only in English"},   // Нет локали — английский вариант
+		{"en", "test.<missing>", nil, "test.&lt;missing&gt;"}, // Нет строки — экранированный ключ
+	}
+	for _, tt := range tests {
+		if got := translate(tt.locale, tt.key, tt.args...); got != tt.want {
+			t.Errorf("translate(%q, %q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRequestLocale(t *testing.T) {
+	tests := []struct {
+		target, acceptLanguage, want string
+	}{
+		{"/", "", "en"},
+		{"/", "ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"/", "de-DE,ru;q=0.5", "ru"},
+		{"/", "de-DE", "en"},
+		{"/?ui=ru", "en-US", "ru"},
+		{"/?ui=xx", "ru", "ru"},
+		{"/?lang=ru", "en-US", "en"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		if got := requestLocale(r); got != tt.want {
+			t.Errorf("requestLocale(%s, %q) = %q, want %q", tt.target, tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestTimeAgo(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	oldClock := clock
+	clock = func() time.Time { return now }
+	defer func() { clock = oldClock }()
+
+	tests := []struct {
+		locale string
+		ago    time.Duration
+		want   string
+	}{
+		{"en", -time.Minute, "just now"},
+		{"en", time.Second, "1 second ago"},
+		{"en", 5 * time.Minute, "5 minutes ago"},
+		{"en", time.Hour, "1 hour ago"},
+		{"en", 3 * 24 * time.Hour, "3 days ago"},
+		{"ru", 0, "только что"},
+		{"ru", 21 * time.Second, "21 секунду назад"},
+		{"ru", 3 * time.Minute, "3 минуты назад"},
+		{"ru", 11 * time.Minute, "11 минут назад"},
+		{"ru", 22 * time.Hour, "22 часа назад"},
+		{"ru", 5 * 24 * time.Hour, "5 дней назад"},
+		{"de", 2 * time.Hour, "2 hours ago"}, // Нет локали — английский вариант
+	}
+	for _, tt := range tests {
+		a := &Article{PublishedAt: now.Add(-tt.ago)}
+		if got := a.TimeAgo(tt.locale); got != tt.want {
+			t.Errorf("TimeAgo(%q) %v ago = %q, want %q", tt.locale, tt.ago, got, tt.want)
+		}
+	}
+}
+
+func TestPluralForm(t *testing.T) {
+	want := map[int]string{1: "one", 2: "few", 4: "few", 5: "many", 11: "many", 12: "many", 14: "many", 21: "one", 22: "few", 25: "many", 101: "one", 111: "many"}
+	for n, form := range want {
+		if got := pluralForm("ru", n); got != form {
+			t.Errorf("pluralForm(ru, %d) = %q, want %q", n, got, form)
+		}
+	}
+	if pluralForm("en", 1) != "one" || pluralForm("en", 21) != "other" {
+		t.Error("English plural forms are not one/other")
+	}
+}
+
+func TestHeadlinesLinksDoNotFilterByLocale(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/headlines?category=science", nil)
+	r.Header.Set("Accept-Language", "ru-RU,ru;q=0.9")
+	headlinesHandler(rec, r)
+	body := rec.Body.String()
+
+	if !strings.Contains(body, `<html lang="ru"`) {
+		t.Fatalf("page is not rendered in the Accept-Language locale:\n%s", body)
+	}
+	if strings.Contains(body, "lang=ru") || strings.Contains(body, `name="lang" value="ru"`) {
+		t.Errorf("headlines page writes the UI locale into the lang filter:\n%s", body)
+	}
+}
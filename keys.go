@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAllKeysExhausted — все ключи API уперлись в лимит NewsAPI и еще
+// не остыли.
+var ErrAllKeysExhausted = fmt.Errorf("%w: all API keys are cooling down", ErrRateLimited)
+
+var keyCooldown = time.Hour // Сколько не трогать ключ после ответа 429
+
+// keyList — значение флага -apikey: флаг можно повторять, а каждое значение
+// может быть списком через запятую. Первое явное значение заменяет ключи из
+// окружения.
+type keyList struct {
+	keys     []string
+	explicit bool
+}
+
+// newKeyList берет ключи по умолчанию из APIKEYS, а если его нет — из APIKEY.
+func newKeyList(env func(string) string) *keyList {
+	l := &keyList{}
+	l.add(env("APIKEYS"))
+	if len(l.keys) == 0 {
+		l.add(env("APIKEY"))
+	}
+	return l
+}
+
+func (l *keyList) add(value string) {
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			l.keys = append(l.keys, key)
+		}
+	}
+}
+
+// String показывает только хвосты ключей, чтобы -help их не раскрыл.
+func (l *keyList) String() string {
+	if l == nil {
+		return ""
+	}
+	masked := make([]string, len(l.keys))
+	for i, key := range l.keys {
+		masked[i] = apiKeyHash(key)
+	}
+	return strings.Join(masked, ",")
+}
+
+func (l *keyList) Set(value string) error {
+	if !l.explicit {
+		l.keys = nil
+		l.explicit = true
+	}
+	l.add(value)
+	return nil
+}
+
+// useKeyFile читает ключи из файла секрета вроде /run/secrets/apikey: по одному
+// на строку или через запятую. Ключи из файла заменяют взятые из .env, но не
+// из -apikey и не из настоящего окружения (envKeys). Нечитаемый или пустой
+// файл — ошибка, даже если его ключи не понадобятся.
+func (l *keyList) useKeyFile(path string, envKeys bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := &keyList{}
+	file.add(strings.ReplaceAll(string(data), "\n", ","))
+	if len(file.keys) == 0 {
+		return fmt.Errorf("%s: file contains no API key", path)
+	}
+	if !l.explicit && !envKeys {
+		l.keys = file.keys
+	}
+	return nil
+}
+
+// keyRing раздает ключи API по кругу и пропускает те, что недавно получили
+// 429, до конца их cooldown. У каждого ключа своя квота NewsAPI.
+type keyRing struct {
+	mu        sync.Mutex
+	keys      []string
+	exhausted map[string]time.Time   // Ключ -> когда его снова можно пробовать
+	quotas    map[string]*quotaState // Ключ -> его квота по заголовкам X-RateLimit-*
+	next      int
+}
+
+func newKeyRing(keys []string) *keyRing {
+	return &keyRing{keys: keys, exhausted: make(map[string]time.Time), quotas: make(map[string]*quotaState)}
+}
+
+// quota возвращает состояние квоты ключа key, заводя его при первом
+// обращении с порогами -quotalow и -quotadelay.
+func (k *keyRing) quota(key string) *quotaState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	q, ok := k.quotas[key]
+	if !ok {
+		q = &quotaState{low: quotaLow, slowDelay: quotaSlowDelay}
+		k.quotas[key] = q
+	}
+	return q
+}
+
+// quotaRetryAfter возвращает число секунд до сброса квоты первого из ключей,
+// исчерпавших ее (не меньше 1).
+func (k *keyRing) quotaRetryAfter(now time.Time) int {
+	k.mu.Lock()
+	quotas := make([]*quotaState, 0, len(k.quotas))
+	for _, q := range k.quotas {
+		quotas = append(quotas, q)
+	}
+	k.mu.Unlock()
+
+	soonest := 0
+	for _, q := range quotas {
+		if s := q.Status(); !s.Known || s.Remaining > 0 {
+			continue
+		}
+		if secs := q.retryAfter(now); soonest == 0 || secs < soonest {
+			soonest = secs
+		}
+	}
+	return max(soonest, 1)
+}
+
+// Len возвращает число настроенных ключей.
+func (k *keyRing) Len() int {
+	return len(k.keys)
+}
+
+// pick возвращает текущий ключ, пропуская остывающие, или
+// ErrAllKeysExhausted, если доступных ключей нет.
+func (k *keyRing) pick(now time.Time) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i := range k.keys {
+		idx := (k.next + i) % len(k.keys)
+		key := k.keys[idx]
+		if until, ok := k.exhausted[key]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(k.exhausted, key)
+		}
+		k.next = idx
+		return key, nil
+	}
+	return "", ErrAllKeysExhausted
+}
+
+// markExhausted откладывает ключ до until и переключает очередь на
+// следующий.
+func (k *keyRing) markExhausted(key string, until time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.exhausted[key] = until
+	if len(k.keys) > 0 && k.keys[k.next] == key {
+		k.next = (k.next + 1) % len(k.keys)
+	}
+}
+
+// retryAfter возвращает число секунд до того, как остынет первый ключ
+// (не меньше 1).
+func (k *keyRing) retryAfter(now time.Time) int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var soonest time.Time
+	for _, until := range k.exhausted {
+		if soonest.IsZero() || until.Before(soonest) {
+			soonest = until
+		}
+	}
+	if secs := int(soonest.Sub(now).Seconds()); secs > 0 {
+		return secs
+	}
+	return 1
+}
+
+// KeyStatus — состояние одного ключа для /admin/stats; сам ключ скрыт.
+type KeyStatus struct {
+	Key            string      `json:"key"`
+	ExhaustedUntil time.Time   `json:"exhaustedUntil"` // Нулевое, если ключ доступен
+	Quota          QuotaStatus `json:"quota"`
+}
+
+// Status возвращает состояние всех ключей в порядке настройки.
+func (k *keyRing) Status(now time.Time) []KeyStatus {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	status := make([]KeyStatus, len(k.keys))
+	for i, key := range k.keys {
+		status[i].Key = apiKeyHash(key)
+		if until, ok := k.exhausted[key]; ok && now.Before(until) {
+			status[i].ExhaustedUntil = until
+		}
+		if q, ok := k.quotas[key]; ok {
+			status[i].Quota = q.Status()
+		}
+	}
+	return status
+}
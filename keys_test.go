@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUseKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikey")
+	if err := os.WriteFile(path, []byte("file-key-1\nfile-key-2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fromEnv := func(string) string { return "env-key" }
+
+	tests := []struct {
+		name    string
+		flag    string // Значение -apikey; пустое — флаг не задан
+		envKeys bool   // APIKEY задан в окружении, а не в .env
+		want    []string
+	}{
+		{"file beats .env", "", false, []string{"file-key-1", "file-key-2"}},
+		{"env beats file", "", true, []string{"env-key"}},
+		{"flag beats file", "flag-key", false, []string{"flag-key"}},
+	}
+	for _, tt := range tests {
+		keys := newKeyList(fromEnv)
+		if tt.flag != "" {
+			keys.Set(tt.flag)
+		}
+		if err := keys.useKeyFile(path, tt.envKeys); err != nil {
+			t.Fatalf("%s: useKeyFile: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(keys.keys, tt.want) {
+			t.Errorf("%s: keys = %v, want %v", tt.name, keys.keys, tt.want)
+		}
+	}
+
+	keys := newKeyList(fromEnv)
+	if err := keys.useKeyFile(filepath.Join(t.TempDir(), "missing"), true); err == nil {
+		t.Error("useKeyFile accepted a missing file")
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	os.WriteFile(empty, []byte("\n"), 0o600)
+	if err := keys.useKeyFile(empty, false); err == nil {
+		t.Error("useKeyFile accepted a file without a key")
+	}
+}
+
+func TestKeyRingTracksQuotaPerKey(t *testing.T) {
+	var used []string
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("apiKey")
+		used = append(used, key)
+		remaining := map[string]string{"spent-key": "0", "fresh-key": "40"}[key]
+		w.Header().Set("X-RateLimit-Remaining", remaining)
+		w.Header().Set("X-RateLimit-Reset", "3600")
+		w.Write([]byte(articlesJSON(1, 1)))
+	})
+	apiKeys = newKeyRing([]string{"spent-key", "fresh-key"})
+
+	request := func() {
+		t.Helper()
+		resp, err := requestWithKeys(context.Background(), "everything", url.Values{"q": {"go"}})
+		if err != nil {
+			t.Fatalf("requestWithKeys: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	request() // spent-key узнает, что его квота исчерпана
+	request() // ...и уступает очередь fresh-key, не сбрасывая свою квоту
+	request()
+
+	if want := []string{"spent-key", "fresh-key", "fresh-key"}; !reflect.DeepEqual(used, want) {
+		t.Errorf("keys used = %v, want %v", used, want)
+	}
+	status := apiKeys.Status(time.Now())
+	if spent := status[0].Quota; !spent.Known || spent.Remaining != 0 || status[0].ExhaustedUntil.IsZero() {
+		t.Errorf("spent key status = %+v, want a known zero quota and a cooldown", status[0])
+	}
+	if fresh := status[1].Quota; !fresh.Known || fresh.Remaining != 40 || !status[1].ExhaustedUntil.IsZero() {
+		t.Errorf("fresh key status = %+v, want 40 remaining and no cooldown", status[1])
+	}
+	if secs := apiKeys.quotaRetryAfter(time.Now()); secs < 3500 || secs > 3600 {
+		t.Errorf("quotaRetryAfter = %d, want about an hour until spent-key resets", secs)
+	}
+}
+
+func TestSingleKeyRateLimitIsNotACooldown(t *testing.T) {
+	var calls int
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":"error","code":"rateLimited","message":"slow down"}`))
+			return
+		}
+		w.Write([]byte(articlesJSON(1, 1)))
+	})
+
+	for _, want := range []int{http.StatusTooManyRequests, http.StatusOK} {
+		resp, err := requestWithKeys(context.Background(), "everything", url.Values{"q": {"go"}})
+		if err != nil {
+			t.Fatalf("requestWithKeys: %v, want the %d response", err, want)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("status = %d, want %d", resp.StatusCode, want)
+		}
+	}
+	if status := apiKeys.Status(time.Now()); !status[0].ExhaustedUntil.IsZero() {
+		t.Errorf("the only key is cooling down until %v after one 429", status[0].ExhaustedUntil)
+	}
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
-	"math"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/joho/godotenv" // Импортируем godotenv
@@ -19,187 +28,1931 @@ import (
 
 var tpl *template.Template // Изменили тип на *template.Template и убрали Must
 
-var apiKey *string
+var notFoundTpl *template.Template // Страница 404
+
+var articleTpl *template.Template // Страница статьи /article
+
+var errorTpl *template.Template // Страница ошибки, см. renderError
+
+var maintenanceTpl *template.Template // Страница режима обслуживания
+
+var apiKeys *keyRing
+
+const defaultAPIURL = "https://newsapi.org/v2"
+
+var apiBaseURL = defaultAPIURL // Адрес NewsAPI без завершающего слэша; меняется для тестов и прокси
+
+var readerModeEnabled bool // Разрешен ли переключатель режима чтения
+
+const readerModeCookie = "reader"
+
+var postRedirect bool // Перенаправлять ли POST /search на GET-адрес
+
+var validateProbe bool // Проверять ли запрос пробным обращением к NewsAPI
+
+var collapseHeadlines bool // Схлопывать ли подряд идущие одинаковые заголовки
+
+var insecureImages = "upgrade" // Что делать с http-картинками на HTTPS-странице: upgrade, hide или keep
+
+var imagePlaceholder = "/assets/placeholder.svg" // Картинка вместо отсутствующей или негодной; пустая — без картинки
+
+var pageAnchor = "results" // Якорь блока результатов для ссылок пагинации; пустой отключает
+
+var maxTotalResults = 100000 // Верхняя граница TotalResults при расчете страниц
+
+var trustedSources = trustSet{} // Проверенные источники для значка и фильтра trusted=1
+
+var pageWindowRadius = 3 // Сколько номеров страниц показывать по обе стороны от текущей
+
+var firstLastLinks = true // Показывать ли ссылки на первую и последнюю страницы
+
+var trackClicks bool // Считать ли переходы по статьям через /out
+
+var clicks = newClickCounter()
+
+var pins = &pinStore{} // Закрепленные редакцией статьи
+
+var pageCache = newTTLCache[renderedPage](30*time.Second, 500) // Отрисованные страницы поиска
+
+var resultsCache = newTTLCache[Results](5*time.Minute, 1000) // Ответы NewsAPI по (query, pageSize, page)
+
+var history = newSearchHistory(1000, 7*24*time.Hour) // Недавние поисковые запросы
+
+// searchNews — поиск статей, которым пользуются обработчики. Переменная, а не
+// прямой вызов getNews, чтобы тесты могли подменить NewsAPI целиком.
+var searchNews = getNews
+
+var (
+	// ErrInvalidPage — номер страницы меньше 1 (NewsAPI нумерует страницы с 1).
+	ErrInvalidPage = errors.New("page number must be 1 or greater")
+	// ErrPageOutOfRange — запрошенная страница лежит за последней страницей выдачи.
+	ErrPageOutOfRange = errors.New("page number is beyond the last page")
+	// ErrUpstreamTimeout — NewsAPI не ответил за отведенное время.
+	ErrUpstreamTimeout = fmt.Errorf("%w: request timed out", ErrUpstreamUnavailable)
+)
+
+const defaultCountry = "us" // Страна главных новостей по умолчанию
+
+const dateLayout = "2006-01-02" // Формат дат from/to
+
+const defaultSortBy = "publishedAt"
+
+var defaultPageSize = 20 // Размер страницы, если параметр pageSize не задан
+
+// hostnamePattern — имя хоста из меток через точку, минимум две метки.
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+const maxPageSize = 100 // Больше NewsAPI не отдает за один запрос
+
+var defaultLanguage = "en" // Язык статей, если параметр lang не задан
+
+// newsLanguages — языки статей, которые поддерживает /v2/everything.
+var newsLanguages = []string{"ar", "de", "en", "es", "fr", "he", "it", "nl", "no", "pt", "ru", "sv", "ud", "zh"}
+
+// sortOrders — значения sortBy, которые понимает /v2/everything.
+var sortOrders = []string{"relevancy", "popularity", "publishedAt"}
+
+// newsCountries — страны, для которых /v2/top-headlines отдает главные новости.
+var newsCountries = []string{
+	"ae", "ar", "at", "au", "be", "bg", "br", "ca", "ch", "cn", "co", "cu", "cz", "de",
+	"eg", "fr", "gb", "gr", "hk", "hu", "id", "ie", "il", "in", "it", "jp", "kr", "lt",
+	"lv", "ma", "mx", "my", "ng", "nl", "no", "nz", "ph", "pl", "pt", "ro", "rs", "ru",
+	"sa", "se", "sg", "si", "sk", "th", "tr", "tw", "ua", "us", "ve", "za",
+}
+
+// newsCategories — категории, которые поддерживает /v2/top-headlines.
+var newsCategories = []string{"business", "entertainment", "general", "health", "science", "sports", "technology"}
+
+// pageRangeError сообщает, какая страница была запрошена и какая последняя.
+// Совпадает с ErrPageOutOfRange через errors.Is.
+type pageRangeError struct {
+	Page     int
+	LastPage int
+}
+
+func (e *pageRangeError) Error() string {
+	return fmt.Sprintf("page %d of %d: %v", e.Page, e.LastPage, ErrPageOutOfRange)
+}
+
+func (e *pageRangeError) Unwrap() error {
+	return ErrPageOutOfRange
+}
+
+// httpClient — общий клиент для всех запросов к NewsAPI, чтобы соединения
+// переиспользовались. Транспорт пересоздается в main по флагам.
+var httpClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: newNewsTransport(upstreamIdleConns, upstreamIdleTimeout, upstreamCompression),
+}
+
+var (
+	maxAttempts   = 3                      // Сколько раз пробовать запрос при 429 и 5xx
+	retryBackoff  = 500 * time.Millisecond // Задержка перед первым повтором, дальше удваивается
+	maxRetryDelay = 10 * time.Second       // Больший Retry-After не ждем, а сразу возвращаем ошибку
+)
 
 type Search struct {
-	SearchKey    string
-	CurrentPage  int
+	SearchKey      string   `json:"searchKey"`
+	CurrentPage    int      `json:"currentPage"`
+	TotalPages     int      `json:"totalPages"`
+	PreviousPage   int      `json:"previousPage"`
+	NextPage       int      `json:"nextPage"`
+	FirstPage      int      `json:"firstPage"` // 1, если текущая страница не первая, иначе 0
+	LastPage       int      `json:"lastPage"`  // TotalPages, если текущая страница не последняя, иначе 0
+	Results        Results  `json:"results"`
+	ResultsLimited bool     `json:"resultsLimited,omitempty"` // Пролистать можно только первые resultLimit результатов
+	ReaderMode     bool     `json:"-"`
+	Theme          string   `json:"-"` // light, dark или auto из cookie theme
+	Locale         string   `json:"-"`
+	TrackClicks    bool     `json:"-"`
+	Secure         bool     `json:"-"` // Страница открыта по HTTPS
+	PageAnchor     string   `json:"-"` // Якорь, к которому ведут ссылки пагинации
+	Trusted        trustSet `json:"-"`
+	PinPopular     bool     `json:"pinPopular,omitempty"` // Проверенные источники поднимаются наверх выдачи по дате
+	TrustedOnly    bool     `json:"trustedOnly,omitempty"`
+	Headlines      bool     `json:"headlines,omitempty"` // Главные новости вместо поиска
+	Country        string   `json:"country,omitempty"`   // Страна главных новостей, только для Headlines
+	Category       string   `json:"category,omitempty"`
+	From           string   `json:"from,omitempty"` // Начало диапазона дат, YYYY-MM-DD
+	To             string   `json:"to,omitempty"`   // Конец диапазона дат, YYYY-MM-DD
+	SortBy         string   `json:"sortBy,omitempty"`
+	Language       string   `json:"language,omitempty"` // Язык статей из параметра lang; пустой — английский
+	Author         string   `json:"author,omitempty"`   // Фильтр по автору в пределах страницы выдачи
+	PageSize       int      `json:"pageSize,omitempty"`
+	MaxAgeDays     int      `json:"maxAgeDays,omitempty"` // Статьи старше стольких дней убираются со страницы; 0 — без фильтра
+	Domains        string   `json:"domains,omitempty"`
+	ExcludeDomains string   `json:"excludeDomains,omitempty"`
+	SearchIn       string   `json:"searchIn,omitempty"`
+	PageWindow     []int    `json:"-"` // Номера страниц вокруг текущей для нумерованных ссылок
+	NoResults      bool     `json:"-"` // Поиск выполнен, но показать нечего (в отличие от пустой главной)
+	RecentSearches []string `json:"-"` // Прошлые запросы посетителя из cookie, кроме текущего
+	EmptyQuery     bool     `json:"-"` // Отправлен пустой поиск: просим ввести запрос
+	PageTitle      string   `json:"-"` // <title> и og:title
+	Description    string   `json:"-"` // Описание для превью ссылки
+	CanonicalURL   string   `json:"-"` // Абсолютный адрес страницы для og:url и rel=canonical
+
+	Related       []Article       `json:"related,omitempty"` // Похожие на первую статью выдачи, не больше maxRelated
+	Bookmarked    map[string]bool `json:"-"`                 // Адреса статей из закладок посетителя
+	BookmarksPage bool            `json:"-"`                 // Страница /bookmarks вместо выдачи
+	Facets        []SourceFacet   `json:"facets,omitempty"`  // Статьи выдачи по источникам, только для поиска
+	HomeHeadlines []Article       `json:"-"`                 // Главные новости на главной из фонового обновления
+	HomeLoading   bool            `json:"-"`                 // Главные новости для главной еще ни разу не загрузились
+}
+
+// ResultLimit — сколько результатов можно пролистать, для подсказки в шаблоне.
+func (s *Search) ResultLimit() int {
+	return resultLimit
+}
+
+// IsBookmarked сообщает, есть ли статья с адресом articleURL в закладках.
+func (s *Search) IsBookmarked(articleURL string) bool {
+	return s.Bookmarked[articleURL]
+}
+
+// Themes возвращает допустимые темы для переключателя.
+func (s *Search) Themes() []string {
+	return themes
+}
+
+// SortOrders возвращает допустимые значения sortBy для выпадающего списка.
+func (s *Search) SortOrders() []string {
+	return sortOrders
+}
+
+// Categories возвращает категории главных новостей для вкладок.
+func (s *Search) Categories() []string {
+	return newsCategories
+}
+
+// Countries возвращает страны главных новостей для выпадающего списка.
+func (s *Search) Countries() []string {
+	return newsCountries
+}
+
+// HeadlinesURL — адрес вкладки главных новостей категории category (пустая —
+// все категории) с сохранением выбранной страны.
+func (s *Search) HeadlinesURL(category string) string {
+	v := url.Values{}
+	if category != "" {
+		v.Set("category", category)
+	}
+	if s.Country != "" && s.Country != defaultCountry {
+		v.Set("country", s.Country)
+	}
+	if len(v) == 0 {
+		return "/headlines"
+	}
+	return "/headlines?" + v.Encode()
+}
+
+// query возвращает параметры текущего поиска для страницы page.
+func (s *Search) query(page int) url.Values {
+	v := url.Values{}
+	v.Set("q", s.SearchKey)
+	v.Set("page", strconv.Itoa(page))
+	if s.Language != "" {
+		v.Set("lang", s.Language)
+	}
+	if s.Domains != "" {
+		v.Set("domains", s.Domains)
+	}
+	if s.ExcludeDomains != "" {
+		v.Set("excludeDomains", s.ExcludeDomains)
+	}
+	if s.SearchIn != "" {
+		v.Set("searchIn", s.SearchIn)
+	}
+	if s.HasCustomPageSize() {
+		v.Set("pageSize", strconv.Itoa(s.PageSize))
+	}
+	if s.Author != "" {
+		v.Set("author", s.Author)
+	}
+	if s.MaxAgeDays > 0 {
+		v.Set("maxAgeDays", strconv.Itoa(s.MaxAgeDays))
+	}
+	if s.TrustedOnly {
+		v.Set("trusted", "1")
+	}
+	if s.PinPopular {
+		v.Set("pinPopular", "1")
+	}
+	if s.Category != "" {
+		v.Set("category", s.Category)
+	}
+	if s.Country != "" && s.Country != defaultCountry {
+		v.Set("country", s.Country)
+	}
+	if s.From != "" {
+		v.Set("from", s.From)
+	}
+	if s.To != "" {
+		v.Set("to", s.To)
+	}
+	if s.SortBy != "" && s.SortBy != defaultSortBy {
+		v.Set("sortBy", s.SortBy)
+	}
+	return v
+}
+
+// Highlight выделяет в text слова поискового запроса тегами <mark>.
+// Без запроса текст только экранируется.
+func (s *Search) Highlight(text string) template.HTML {
+	return highlightTerms(text, queryTerms(s.SearchKey))
+}
+
+// newsQuery возвращает параметры запроса к NewsAPI для текущего поиска.
+func (s *Search) newsQuery() newsQuery {
+	return newsQuery{
+		Query:          s.SearchKey,
+		From:           s.From,
+		To:             s.To,
+		SortBy:         s.SortBy,
+		Language:       s.Language,
+		Domains:        s.Domains,
+		ExcludeDomains: s.ExcludeDomains,
+		SearchIn:       s.SearchIn,
+	}
+}
+
+// PageURL возвращает адрес страницы page текущего поиска со всеми его параметрами.
+func (s *Search) PageURL(page int) string {
+	path := "/search"
+	if s.Headlines {
+		path = "/headlines"
+	}
+	u := path + "?" + s.query(page).Encode()
+	if s.PageAnchor != "" {
+		u += "#" + s.PageAnchor
+	}
+	return u
+}
+
+// FeedURL возвращает адрес RSS-ленты по запросу; для главных новостей ленты нет.
+func (s *Search) FeedURL() string {
+	if s.Headlines || s.SearchKey == "" {
+		return ""
+	}
+	return "/feed?" + url.Values{"q": {s.SearchKey}}.Encode()
+}
+
+// CSVExportURL возвращает адрес CSV-выгрузки текущей страницы. Выгрузка
+// ищет через /everything, поэтому для главных новостей ее нет.
+func (s *Search) CSVExportURL() string {
+	if s.Headlines || s.SearchKey == "" {
+		return ""
+	}
+	return "/export?" + s.query(s.CurrentPage).Encode()
+}
+
+// ImagePlaceholder — адрес картинки-заглушки для шаблона.
+func (s *Search) ImagePlaceholder() string {
+	return imagePlaceholder
+}
+
+// ExportURL возвращает адрес JSON-выгрузки текущей страницы; для главных
+// новостей выгрузки нет, как и у CSVExportURL.
+func (s *Search) ExportURL() string {
+	if s.Headlines || s.SearchKey == "" {
+		return ""
+	}
+	return "/export.json?" + s.query(s.CurrentPage).Encode()
+}
+
+// T возвращает перевод строки интерфейса для локали страницы.
+func (s *Search) T(key string, args ...interface{}) template.HTML {
+	return translate(s.Locale, key, args...)
+}
+
+// Snapshot — выгрузка текущей выдачи вместе с метаданными запроса.
+// Порядок полей в JSON совпадает с порядком полей в структуре.
+type Snapshot struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Search      *Search   `json:"search"`
+}
+
+// IsLastPage проверяет, является ли текущая страница последней.
+func (s *Search) IsLastPage() bool {
+	return s.CurrentPage >= s.TotalPages
+}
+
+// HasPreviousPage проверяет, есть ли предыдущая страница.
+func (s *Search) HasPreviousPage() bool {
+	return s.CurrentPage > 1
+}
+
+func (s *Search) HasNextPage() bool { // <-- Важно: HasNextPage с большой буквы
+	return s.CurrentPage < s.TotalPages
+}
+
+// HasFirstPage проверяет, нужна ли ссылка на первую страницу.
+func (s *Search) HasFirstPage() bool {
+	return s.FirstPage > 0
+}
+
+// HasLastPage проверяет, нужна ли ссылка на последнюю страницу.
+func (s *Search) HasLastPage() bool {
+	return s.LastPage > 0
+}
+
+// HasCustomPageSize сообщает, отличается ли размер страницы от
+// defaultPageSize и нужно ли передавать его в ссылках.
+func (s *Search) HasCustomPageSize() bool {
+	return s.PageSize != 0 && s.PageSize != defaultPageSize
+}
+
+// paginate вычисляет TotalPages, PreviousPage, NextPage, FirstPage, LastPage
+// и окно номеров страниц для CurrentPage.
+func (s *Search) paginate(totalResults, pageSize int) {
+	p := computePagination(totalResults, pageSize, s.CurrentPage, resultLimit)
+	s.TotalPages, s.PreviousPage, s.NextPage = p.TotalPages, p.PreviousPage, p.NextPage
+	s.ResultsLimited = p.Limited
+	s.FirstPage = firstPage(s.CurrentPage)
+	s.LastPage = lastPage(s.CurrentPage, s.TotalPages)
+	s.PageWindow = pageWindow(s.CurrentPage, s.TotalPages, pageWindowRadius)
+}
+
+// ShowFirstLink сообщает, нужна ли ссылка на первую страницу рядом с окном,
+// то есть есть ли первая страница и не попала ли она в само окно.
+func (s *Search) ShowFirstLink() bool {
+	return firstLastLinks && s.HasFirstPage() && len(s.PageWindow) > 0 && s.PageWindow[0] > s.FirstPage
+}
+
+// ShowLastLink сообщает, нужна ли ссылка на последнюю страницу рядом с окном.
+func (s *Search) ShowLastLink() bool {
+	return firstLastLinks && s.HasLastPage() && len(s.PageWindow) > 0 && s.PageWindow[len(s.PageWindow)-1] < s.LastPage
+}
+
+// HasLeadingGap сообщает, пропущены ли страницы перед окном (рисуется "…").
+func (s *Search) HasLeadingGap() bool {
+	if len(s.PageWindow) == 0 {
+		return false
+	}
+	if s.ShowFirstLink() {
+		return s.PageWindow[0] > 2
+	}
+	return s.PageWindow[0] > 1
+}
+
+// HasTrailingGap сообщает, пропущены ли страницы после окна (рисуется "…").
+func (s *Search) HasTrailingGap() bool {
+	if len(s.PageWindow) == 0 {
+		return false
+	}
+	last := s.PageWindow[len(s.PageWindow)-1]
+	if s.ShowLastLink() {
+		return last < s.TotalPages-1
+	}
+	return last < s.TotalPages
+}
+
+// pageWindow возвращает номера страниц в пределах radius от current,
+// не выходя за 1..total. Для одной страницы окно не нужно.
+func pageWindow(current, total, radius int) []int {
+	if total <= 1 || current < 1 || current > total {
+		return nil
+	}
+	from := max(current-radius, 1)
+	to := min(current+radius, total)
+	window := make([]int, 0, to-from+1)
+	for p := from; p <= to; p++ {
+		window = append(window, p)
+	}
+	return window
+}
+
+// totalPages возвращает число страниц выдачи. Пустая выдача занимает одну страницу.
+func totalPages(totalResults, pageSize int) int {
+	if totalResults <= 0 || pageSize <= 0 {
+		return 1
+	}
+	pages := totalResults / pageSize // Без сложения, чтобы не переполнить int на огромных значениях
+	if totalResults%pageSize != 0 {
+		pages++
+	}
+	return pages
+}
+
+// pagination — число страниц выдачи и соседние с текущей, см. computePagination.
+type pagination struct {
 	TotalPages   int
-	PreviousPage int
-	NextPage     int
-	Results      Results
+	PreviousPage int  // 0 — предыдущей нет
+	NextPage     int  // 0 — следующей нет
+	Limited      bool // TotalPages урезано до hardCap
+}
+
+// computePagination считает страницы выдачи из totalResults результатов по
+// pageSize для currentPage. hardCap — сколько результатов NewsAPI позволяет
+// пролистать (0 — без ограничения): страниц за ним не бывает, даже если
+// totalResults больше, и ссылки на них не показываются. Страница всегда есть
+// хотя бы одна, а предыдущая и следующая не выходят за 1..TotalPages.
+func computePagination(totalResults, pageSize, currentPage, hardCap int) pagination {
+	p := pagination{TotalPages: totalPages(totalResults, pageSize)}
+	if last := lastAllowedPage(hardCap, pageSize); last > 0 && p.TotalPages > last {
+		p.TotalPages = last
+		p.Limited = true
+	}
+	if prev := previousPage(currentPage); prev > 0 {
+		p.PreviousPage = min(prev, p.TotalPages)
+	}
+	p.NextPage = nextPage(currentPage, p.TotalPages)
+	return p
+}
+
+// clampTotalResults ограничивает неправдоподобно большое TotalResults от NewsAPI
+// значением maxTotalResults (0 отключает ограничение).
+func clampTotalResults(totalResults int) int {
+	if maxTotalResults > 0 && totalResults > maxTotalResults {
+		log.Printf("Clamping TotalResults from %d to %d", totalResults, maxTotalResults)
+		return maxTotalResults
+	}
+	return totalResults
+}
+
+// previousPage возвращает номер предыдущей страницы или 0, если ее нет.
+func previousPage(current int) int {
+	if current > 1 {
+		return current - 1
+	}
+	return 0
+}
+
+// firstPage возвращает 1 или 0, если current уже первая страница.
+func firstPage(current int) int {
+	if current > 1 {
+		return 1
+	}
+	return 0
+}
+
+// lastPage возвращает total или 0, если current уже последняя страница.
+func lastPage(current, total int) int {
+	if current < total {
+		return total
+	}
+	return 0
+}
+
+// nextPage возвращает номер следующей страницы или 0, если ее нет.
+func nextPage(current, total int) int {
+	if current < total {
+		return current + 1
+	}
+	return 0
+}
+
+type Source struct {
+	ID   string `json:"id"` // Пустой, если NewsAPI прислал null
+	Name string `json:"name"`
+}
+
+// UnmarshalJSON принимает id строкой, числом или null: NewsAPI присылает
+// null для источников без идентификатора. Прочие значения дают пустой ID.
+func (s *Source) UnmarshalJSON(data []byte) error {
+	type source Source
+	aux := struct {
+		*source
+		ID json.RawMessage `json:"id"`
+	}{source: (*source)(s)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	s.ID = ""
+	if len(aux.ID) == 0 || string(aux.ID) == "null" {
+		return nil
+	}
+	var id string
+	if err := json.Unmarshal(aux.ID, &id); err == nil {
+		s.ID = id
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(aux.ID, &number); err == nil {
+		s.ID = number.String()
+		return nil
+	}
+	log.Printf("Unexpected source id %s for %q", aux.ID, s.Name) // Не повод терять всю выдачу
+	return nil
+}
+
+// DisplayName возвращает название источника или "Unknown source".
+func (s Source) DisplayName() string {
+	if s.Name == "" {
+		return "Unknown source"
+	}
+	return s.Name
+}
+
+type Article struct {
+	Source      Source    `json:"source"`
+	Author      string    `json:"author"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	URL         string    `json:"url"`
+	URLToImage  string    `json:"urlToImage"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Content     string    `json:"content"`
+	Pinned      bool      `json:"pinned,omitempty"` // Закреплена редакцией, в ответе NewsAPI не бывает
+
+	NewSinceVisit bool `json:"-"` // Опубликована после прошлого визита пользователя
+}
+
+// UnmarshalJSON разбирает статью, не падая на пустой или некорректной дате
+// публикации: в этом случае PublishedAt остается нулевым.
+func (a *Article) UnmarshalJSON(data []byte) error {
+	type article Article
+	aux := struct {
+		*article
+		PublishedAt string `json:"publishedAt"`
+	}{article: (*article)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	a.PublishedAt = time.Time{}
+	if t, err := time.Parse(time.RFC3339, aux.PublishedAt); err == nil {
+		a.PublishedAt = t
+	} else if aux.PublishedAt != "" {
+		log.Printf("Unparseable publishedAt %q for %s", aux.PublishedAt, a.URL)
+	}
+	return nil
+}
+
+// ImageURL возвращает URLToImage, если это корректный http(s)-адрес, и
+// imagePlaceholder иначе: пустое поле, мусор и схемы вроде javascript: или
+// data: в страницу не попадают.
+func (a *Article) ImageURL() string {
+	u, err := url.Parse(strings.TrimSpace(a.URLToImage))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return imagePlaceholder
+	}
+	return u.String()
+}
+
+// DisplayImageURL возвращает ImageURL с учетом того, открыта ли страница
+// по HTTPS: http-картинки на такой странице обрабатываются по insecureImages.
+func (a *Article) DisplayImageURL(secure bool) string {
+	image := a.ImageURL()
+	if !secure || !strings.HasPrefix(image, "http://") {
+		return image
+	}
+	switch insecureImages {
+	case "upgrade":
+		return "https://" + image[len("http://"):]
+	case "hide":
+		return imagePlaceholder
+	}
+	return image
+}
+
+// HasPublishedDate сообщает, известна ли дата публикации статьи.
+func (a *Article) HasPublishedDate() bool {
+	return !a.PublishedAt.IsZero()
+}
+
+// FormatPublishedDate форматирует дату публикации статьи в формате -locale.
+func (a *Article) FormatPublishedDate() string {
+	return a.FormatPublishedDateIn(publishedDateLocale)
+}
+
+const (
+	wordsPerMinute  = 200 // Средняя скорость чтения
+	avgCharsPerWord = 6   // Вместе с пробелом; для оценки обрезанного хвоста
+)
+
+// truncatedMarker — хвост вроде "… [+1234 chars]", которым NewsAPI обрезает Content.
+var truncatedMarker = regexp.MustCompile(`\s*\[\+(\d+) chars\]\s*$`)
+
+// ReadingTime оценивает время чтения статьи вроде "3 min read". Считаются
+// слова Content (или Description, если Content пуст); отрезанный NewsAPI
+// хвост пересчитывается в слова по числу символов из маркера. Без текста
+// возвращает пустую строку.
+func (a *Article) ReadingTime() string {
+	text := a.Content
+	if strings.TrimSpace(text) == "" {
+		text = a.Description
+	}
+
+	words := 0
+	if m := truncatedMarker.FindStringSubmatchIndex(text); m != nil {
+		if chars, err := strconv.Atoi(text[m[2]:m[3]]); err == nil {
+			words += chars / avgCharsPerWord
+		}
+		text = text[:m[0]]
+	}
+	words += len(strings.Fields(text))
+	if words == 0 {
+		return ""
+	}
+
+	minutes := max((words+wordsPerMinute-1)/wordsPerMinute, 1)
+	return fmt.Sprintf("%d min read", minutes)
+}
+
+// filterAuthor оставляет статьи, в поле Author которых встречается author
+// без учета регистра.
+func filterAuthor(articles []Article, author string) []Article {
+	author = strings.ToLower(author)
+	out := articles[:0]
+	for i := range articles {
+		if strings.Contains(strings.ToLower(articles[i].Author), author) {
+			out = append(out, articles[i])
+		}
+	}
+	return out
+}
+
+// filterMaxAge убирает статьи, опубликованные раньше cutoff. Статьи без даты
+// публикации остаются: сказать, что они устарели, нельзя.
+func filterMaxAge(articles []Article, cutoff time.Time) []Article {
+	out := articles[:0]
+	for i := range articles {
+		if !articles[i].HasPublishedDate() || !articles[i].PublishedAt.Before(cutoff) {
+			out = append(out, articles[i])
+		}
+	}
+	return out
+}
+
+// sortByPublishedDate упорядочивает статьи от новых к старым.
+// Статьи без даты публикации идут в конце в исходном порядке.
+func sortByPublishedDate(articles []Article) {
+	sort.SliceStable(articles, func(i, j int) bool {
+		a, b := &articles[i], &articles[j]
+		if !a.HasPublishedDate() || !b.HasPublishedDate() {
+			return a.HasPublishedDate() && !b.HasPublishedDate()
+		}
+		return a.PublishedAt.After(b.PublishedAt)
+	})
+}
+
+// collapseConsecutive схлопывает идущие подряд статьи одного источника с
+// одинаковым заголовком, оставляя самую свежую. Неподряд идущие дубли не трогаются.
+func collapseConsecutive(articles []Article) []Article {
+	out := articles[:0]
+	for _, a := range articles {
+		if n := len(out); n > 0 && out[n-1].Title == a.Title && out[n-1].Source.Name == a.Source.Name {
+			if a.PublishedAt.After(out[n-1].PublishedAt) {
+				out[n-1] = a
+			}
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+type Results struct {
+	Status       string    `json:"status"`
+	TotalResults int       `json:"totalResults"`
+	Articles     []Article `json:"articles"`
+	Code         string    `json:"code,omitempty"`    // Только при status "error"
+	Message      string    `json:"message,omitempty"` // Только при status "error"
+}
+
+// dedupe убирает статьи с одинаковым URL или заголовком (без учета регистра
+// и пробелов по краям), оставляя самую раннюю публикацию. Возвращает число
+// выброшенных. TotalResults не меняется: повторы видны только на этой
+// странице, а сколько их во всей выдаче NewsAPI, неизвестно.
+func (r *Results) dedupe() int {
+	byURL := make(map[string]int)
+	byTitle := make(map[string]int)
+	out := r.Articles[:0]
+	for _, a := range r.Articles {
+		title := strings.ToLower(strings.TrimSpace(a.Title))
+		i, dup := byURL[a.URL]
+		if !dup && title != "" {
+			i, dup = byTitle[title]
+		}
+		if !dup {
+			byURL[a.URL] = len(out)
+			if title != "" {
+				byTitle[title] = len(out)
+			}
+			out = append(out, a)
+			continue
+		}
+		if kept := out[i]; a.HasPublishedDate() && (!kept.HasPublishedDate() || a.PublishedAt.Before(kept.PublishedAt)) {
+			out[i] = a
+			byURL[a.URL] = i
+		}
+	}
+
+	removed := len(r.Articles) - len(out)
+	r.Articles = out
+	return removed
+}
+
+// clone возвращает копию результатов с собственным срезом статей.
+func (r Results) clone() Results {
+	r.Articles = append([]Article(nil), r.Articles...)
+	return r
+}
+
+func indexHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" { // "/" зарегистрирован как запасной маршрут для всех путей
+		notFoundHandler(w, r)
+		return
+	}
+	log.Println("indexHandler called")
+	renderHome(w, r, false)
+}
+
+// renderHome отрисовывает главную страницу. emptyQuery — посетитель отправил
+// пустой поиск, и над формой нужно попросить ввести запрос.
+func renderHome(w http.ResponseWriter, r *http.Request, emptyQuery bool) {
+	// Создаем структуру Search с пустыми значениями
+	search := Search{
+		SearchKey:    "",        // Пустой поисковый запрос
+		CurrentPage:  1,         // Первая страница
+		TotalPages:   0,         // Нет результатов (пока)
+		PreviousPage: 0,         // Нет предыдущей страницы
+		NextPage:     0,         // Нет следующей страницы
+		Results:      Results{}, // Пустые результаты
+		ReaderMode:   readerMode(r),
+		Theme:        requestTheme(r),
+		Locale:       requestLocale(r),
+		Secure:       isSecureRequest(r),
+		PageAnchor:   pageAnchor,
+		Trusted:      trustedSources,
+		SortBy:       defaultSortBy,
+		EmptyQuery:   emptyQuery,
+		TrackClicks:  trackClicks,
+	}
+	if lang := r.URL.Query().Get("lang"); isNewsLanguage(lang) {
+		search.Language = lang
+	}
+	if homeRefresh > 0 {
+		var loaded bool
+		search.HomeHeadlines, loaded = home.get()
+		search.HomeLoading = !loaded
+	}
+	search.RecentSearches = recentSearches(r)
+	search.setMeta(r)
+
+	touchLastVisit(w, r)
+
+	// Сначала в буфер: если шаблон упадет на середине, клиент получит чистый
+	// 500, а не обрывок страницы с кодом 200
+	var buf bytes.Buffer
+	err := templates().Index.Execute(&buf, &search) // Передаем структуру Search в шаблон
+	if err != nil {
+		log.Printf("Error executing template: %v", err)
+		renderError(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// notFoundHandler отдает 404 со страницей notfound.html.
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	search := &Search{
+		ReaderMode: readerMode(r),
+		Theme:      requestTheme(r),
+		Locale:     requestLocale(r),
+	}
+
+	var buf bytes.Buffer
+	if err := templates().NotFound.Execute(&buf, search); err != nil {
+		log.Printf("Error executing not found template: %v", err)
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(buf.Bytes())
+}
+
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if postRedirect {
+			redirectToSearch(w, r)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if strictParams {
+		if msg := unknownParamsError(r.URL.Query()); msg != "" {
+			if prefersJSON(r) {
+				writeJSONError(w, http.StatusBadRequest, msg)
+			} else {
+				renderError(w, r, http.StatusBadRequest, msg)
+			}
+			return
+		}
+	}
+	if prefersJSON(r) {
+		searchJSON(w, r)
+		return
+	}
+
+	// Пустой запрос NewsAPI отклоняет с 400; вместо ошибки показываем главную
+	if params, err := searchParams(r); err == nil {
+		if query, err := sanitizeQuery(params.Get("q")); err == nil && query == "" {
+			renderHome(w, r, true)
+			return
+		}
+	}
+
+	renderResults(w, r, false)
+}
+
+// headlinesHandler показывает главные новости NewsAPI, по умолчанию — для США
+// без фильтра по категории.
+func headlinesHandler(w http.ResponseWriter, r *http.Request) {
+	renderResults(w, r, true)
+}
+
+// partialSearchHandler отдает только элементы списка статей (блок "articles"
+// из index.html) для q и page — для подгрузки следующих страниц без
+// перезагрузки. Когда статей больше нет, отвечает 204.
+func partialSearchHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := runSearch(w, r, false)
+	if !ok {
+		return
+	}
+	if len(search.Results.Articles) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := templates().Index.ExecuteTemplate(&buf, "articles", search); err != nil {
+		slog.ErrorContext(r.Context(), "Error executing articles template", "error", err)
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// renderResults отрисовывает страницу результатов поиска или главных новостей,
+// по возможности беря готовую страницу из кэша.
+func renderResults(w http.ResponseWriter, r *http.Request, headlines bool) {
+	touchLastVisit(w, r)
+
+	// Запрос попадает в историю, только когда поиск удался. Ключ кэша и
+	// страница строятся по истории, какой она станет после этого поиска.
+	var query string
+	if !headlines {
+		params, _ := searchParams(r)
+		if q, err := sanitizeQuery(params.Get("q")); err == nil {
+			query = q
+		}
+	}
+	recent := withRecentSearch(recentSearches(r), query)
+
+	key := pageCacheKey(r, recent)
+	if page, ok := pageCache.Get(key); ok {
+		slog.InfoContext(r.Context(), "Page cache hit", "key", key)
+		cacheLookups.Inc("page", "hit")
+		rememberSearch(w, r, query) // В кэш попадают только удачные поиски
+		history.Add(page.audit.query)
+		auditSearch(r, page.audit)
+		writePage(w, r, page)
+		return
+	}
+	cacheLookups.Inc("page", "miss")
+
+	ew := &errorPageWriter{ResponseWriter: w, r: r}
+	search, ok := runSearch(ew, r, headlines)
+	if !ok {
+		ew.finish()
+		return
+	}
+	defer prefetchNextPage(r.Context(), search) // После ответа: посетителю ждать нечего
+	rememberSearch(w, r, query)
+	search.RecentSearches = otherSearches(recent, search.SearchKey)
+
+	site := templates()
+	etag := pageETag(r, search, site.Version)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		writePage(w, r, renderedPage{etag: etag}) // Не отрисовываем: клиенту хватит 304
+		return
+	}
+
+	var buf bytes.Buffer
+	err := site.Index.Execute(&buf, search)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error executing template", "error", err)
+		renderError(w, r, http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+
+	page := renderedPage{body: buf.Bytes(), etag: etag, audit: newSearchAudit(search)}
+	pageCache.Set(key, page)
+	writePage(w, r, page)
+}
+
+// pageCacheKey строит ключ кэша страниц из всего, что влияет на отрисовку:
+// хоста и параметров поиска, локали, режима чтения, темы, времени прошлого визита,
+// схемы, недавних запросов и закладок посетителя.
+func pageCacheKey(r *http.Request, recent []string) string {
+	params, _ := searchParams(r) // Ошибку разбора вернет runSearch
+	var saved []string
+	for _, b := range bookmarks(r) {
+		saved = append(saved, b.URL)
+	}
+	return fmt.Sprintf("%s%s?%s|locale=%s|reader=%t|theme=%s|visit=%d|secure=%t|recent=%q|bookmarks=%q", r.Host, r.URL.Path, params.Encode(), requestLocale(r), readerMode(r), requestTheme(r), previousVisit(r).Unix(), isSecureRequest(r), recent, saved)
+}
+
+// writePage отдает отрисованную страницу поиска или 304, если ее ETag
+// совпал с If-None-Match.
+func writePage(w http.ResponseWriter, r *http.Request, page renderedPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("ETag", page.etag)
+	if pageMaxAge > 0 {
+		// private: в странице cookie посетителя (недавние запросы, отметки "новое")
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(pageMaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.Header().Add("Vary", "Accept-Language, Cookie")
+	if etagMatches(r.Header.Get("If-None-Match"), page.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(page.body)
 }
 
-// IsLastPage проверяет, является ли текущая страница последней.
-func (s *Search) IsLastPage() bool {
-	return s.CurrentPage >= s.TotalPages
+// redirectToSearch проверяет отправленную форму поиска и перенаправляет
+// на эквивалентный GET-адрес, которым можно поделиться (Post/Redirect/Get).
+func redirectToSearch(w http.ResponseWriter, r *http.Request) {
+	params, err := searchParams(r)
+	if err != nil {
+		log.Printf("Error parsing form: %v", err)
+		renderError(w, r, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if _, err := parsePage(params.Get("page")); err != nil {
+		log.Printf("Error converting page to integer: %v", err)
+		renderError(w, r, http.StatusBadRequest, "Invalid page number")
+		return
+	}
+
+	query := url.Values{}
+	for key, values := range params {
+		for _, v := range values {
+			if v != "" {
+				query.Add(key, v)
+			}
+		}
+	}
+	http.Redirect(w, r, "/search?"+query.Encode(), http.StatusSeeOther)
+}
+
+// searchParams возвращает параметры поиска из строки запроса, а для POST —
+// еще и из тела формы (значения формы имеют приоритет).
+func searchParams(r *http.Request) (url.Values, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	return r.Form, nil
+}
+
+// parseDateRange проверяет даты from и to в формате YYYY-MM-DD.
+// Любая из них может быть пустой: тогда диапазон открыт с этой стороны.
+func parseDateRange(from, to string) (string, string, error) {
+	var fromDate, toDate time.Time
+	var err error
+	if from != "" {
+		if fromDate, err = time.Parse(dateLayout, from); err != nil {
+			return "", "", fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if toDate, err = time.Parse(dateLayout, to); err != nil {
+			return "", "", fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+	}
+	if from != "" && to != "" && toDate.Before(fromDate) {
+		return "", "", fmt.Errorf("from date %s is after to date %s", from, to)
+	}
+	return from, to, nil
+}
+
+// parseDomainList проверяет список доменов через запятую и возвращает его
+// в нормализованном виде: без пробелов, пустых элементов и в нижнем регистре.
+func parseDomainList(list string) (string, error) {
+	var domains []string
+	for _, d := range strings.Split(list, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" {
+			continue
+		}
+		if !hostnamePattern.MatchString(d) {
+			return "", fmt.Errorf("invalid domain %q", d)
+		}
+		domains = append(domains, d)
+	}
+	return strings.Join(domains, ","), nil
+}
+
+// searchInFields — поля статьи, которыми NewsAPI позволяет ограничить поиск,
+// в порядке, в котором parseSearchIn их перечисляет.
+var searchInFields = []string{"title", "description", "content"}
+
+// parseSearchIn проверяет список полей searchIn через запятую и приводит его
+// к единому виду: нижний регистр, без повторов, в порядке searchInFields.
+// Пустой список — поиск по всем полям.
+func parseSearchIn(list string) (string, error) {
+	seen := map[string]bool{}
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if !isSearchInField(f) {
+			return "", fmt.Errorf("invalid searchIn field %q: use title, description or content", f)
+		}
+		seen[f] = true
+	}
+	var fields []string
+	for _, f := range searchInFields {
+		if seen[f] {
+			fields = append(fields, f)
+		}
+	}
+	return strings.Join(fields, ","), nil
+}
+
+func isSearchInField(field string) bool {
+	for _, f := range searchInFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePageSize разбирает размер страницы и ограничивает его диапазоном
+// 1..maxPageSize. Пустая строка означает defaultPageSize.
+func parsePageSize(sizeStr string) (int, error) {
+	if sizeStr == "" {
+		return defaultPageSize, nil
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil {
+		return 0, err
+	}
+	return min(max(size, 1), maxPageSize), nil
+}
+
+// parseMaxAgeDays разбирает maxAgeDays. Пустая строка и 0 отключают фильтр,
+// отрицательные значения отклоняются.
+func parseMaxAgeDays(daysStr string) (int, error) {
+	if daysStr == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(daysStr)
+	if err != nil {
+		return 0, err
+	}
+	if days < 0 {
+		return 0, fmt.Errorf("maxAgeDays %d is negative", days)
+	}
+	return days, nil
+}
+
+// parseToggle разбирает параметр-переключатель: "1", "true" и т. п. включают
+// его, все остальное — нет.
+func parseToggle(value string) bool {
+	on, _ := strconv.ParseBool(value)
+	return on
+}
+
+// parsePage разбирает номер страницы. Пустая строка означает первую страницу,
+// номера меньше 1 отклоняются с ErrInvalidPage.
+func parsePage(pageStr string) (int, error) {
+	if pageStr == "" {
+		return 1, nil
+	}
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		return 0, err
+	}
+	if page < 1 {
+		return 0, ErrInvalidPage
+	}
+	return page, nil
+}
+
+// isSecureRequest сообщает, пришел ли запрос по HTTPS, напрямую или через прокси.
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+// readerModeHandler включает или выключает режим чтения через cookie
+// и возвращает пользователя на предыдущую страницу.
+func readerModeHandler(w http.ResponseWriter, r *http.Request) {
+	if !readerModeEnabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode != "on" && mode != "off" {
+		renderError(w, r, http.StatusBadRequest, "Invalid reader mode")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     readerModeCookie,
+		Value:    mode,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, backURL(r), http.StatusSeeOther)
+}
+
+// readerMode читает сохраненную настройку режима чтения. По умолчанию режим выключен.
+func readerMode(r *http.Request) bool {
+	if !readerModeEnabled {
+		return false
+	}
+	c, err := r.Cookie(readerModeCookie)
+	if err != nil {
+		return false
+	}
+	return c.Value == "on"
+}
+
+// QueryValidation — ответ /validate.
+type QueryValidation struct {
+	IsValid          bool     `json:"isValid"`
+	EstimatedResults *int     `json:"estimatedResults,omitempty"`
+	Messages         []string `json:"messages"`
+}
+
+// validateHandler проверяет запрос без полноценного поиска. Если включен
+// -validateprobe, корректный запрос дополнительно проверяется обращением
+// к NewsAPI с pageSize=1, чтобы оценить число результатов.
+func validateHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := sanitizeQuery(r.URL.Query().Get("q"))
+	q = typographicQuotes.Replace(q) // Как при поиске, иначе “фраза" выглядела бы незакрытой
+
+	validation := QueryValidation{Messages: validateQuery(q)}
+	if err != nil {
+		msg := err.Error()
+		validation.Messages = []string{strings.ToUpper(msg[:1]) + msg[1:]}
+	}
+	validation.IsValid = len(validation.Messages) == 0
+
+	if validation.IsValid && validateProbe {
+		results, err := searchNews(r.Context(), newsQuery{Query: q}, 1, 1)
+		if err != nil {
+			log.Printf("Validation probe failed: %v", err)
+			validation.Messages = append(validation.Messages, "Could not estimate the number of results")
+		} else {
+			validation.EstimatedResults = &results.TotalResults
+		}
+	}
+	if validation.Messages == nil {
+		validation.Messages = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(validation); err != nil {
+		log.Printf("Error encoding validation: %v", err)
+	}
+}
+
+// outHandler засчитывает переход по ссылке на статью и перенаправляет на нее.
+// Принимает только ссылки с подписью из Article.OutURL.
+func outHandler(w http.ResponseWriter, r *http.Request) {
+	if !trackClicks {
+		http.NotFound(w, r)
+		return
+	}
+
+	target := r.URL.Query().Get("u")
+	if !validOutboundSignature(target, r.URL.Query().Get("sig")) {
+		log.Printf("Rejected unsigned outbound link %q", target)
+		renderError(w, r, http.StatusBadRequest, "Invalid link")
+		return
+	}
+	if err := validateOutboundURL(target); err != nil {
+		log.Printf("Rejected outbound link %q: %v", target, err)
+		renderError(w, r, http.StatusBadRequest, "Invalid link")
+		return
+	}
+
+	clicks.Record(target)
+	log.Printf("Outbound click: %s", target)
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// exportHandler отдает текущую выдачу в виде JSON-снимка.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := runSearch(w, r, false)
+	if !ok {
+		return
+	}
+
+	search.Results.Articles = transformArticles(search.Results.Articles, exportWorkers, cleanArticle)
+
+	snapshot := Snapshot{
+		GeneratedAt: time.Now().UTC(),
+		Search:      search,
+	}
+
+	body, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding snapshot: %v", err)
+		http.Error(w, "Failed to encode snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="news-snapshot.json"`)
+	w.Write(body)
+}
+
+// runSearch разбирает параметры запроса, обращается к NewsAPI (к поиску или,
+// если headlines, к главным новостям) и заполняет Search.
+// При ошибке ответ клиенту уже записан и возвращается false.
+func runSearch(w http.ResponseWriter, r *http.Request, headlines bool) (*Search, bool) {
+	// Get parameters from the URL or the submitted form
+	params, err := searchParams(r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error parsing form", "error", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return nil, false
+	}
+
+	searchKey, err := sanitizeQuery(params.Get("q"))
+	if err == nil {
+		searchKey, err = normalizeQuery(searchKey)
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Rejected search query", "error", err)
+		http.Error(w, queryErrorMessage(err), http.StatusBadRequest)
+		return nil, false
+	}
+
+	pageSize, err := parsePageSize(params.Get("pageSize"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error converting pageSize to integer", "error", err)
+		http.Error(w, "Invalid page size", http.StatusBadRequest)
+		return nil, false
+	}
+
+	page, err := parsePage(params.Get("page"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error converting page to integer", "error", err)
+		http.Error(w, "Invalid page number", http.StatusBadRequest)
+		return nil, false
+	}
+
+	maxAgeDays, err := parseMaxAgeDays(params.Get("maxAgeDays"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Invalid maxAgeDays", "error", err)
+		http.Error(w, "Invalid maxAgeDays", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// Create a Search struct
+	search := &Search{
+		SearchKey:   searchKey,
+		PageSize:    pageSize,
+		CurrentPage: page,
+		ReaderMode:  readerMode(r),
+		Theme:       requestTheme(r),
+		Locale:      requestLocale(r),
+		TrackClicks: trackClicks,
+		Secure:      isSecureRequest(r),
+		PageAnchor:  pageAnchor,
+		Trusted:     trustedSources,
+		TrustedOnly: params.Get("trusted") == "1",
+		PinPopular:  parseToggle(params.Get("pinPopular")),
+		Author:      strings.TrimSpace(params.Get("author")),
+		MaxAgeDays:  maxAgeDays,
+		Headlines:   headlines,
+		Bookmarked:  bookmarkedURLs(r),
+		Category:    strings.ToLower(params.Get("category")),
+	}
+
+	from, to, err := parseDateRange(params.Get("from"), params.Get("to"))
+	if err != nil {
+		slog.WarnContext(r.Context(), "Invalid date range", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	search.From, search.To = from, to
+
+	search.Language = strings.ToLower(params.Get("lang"))
+	if search.Language != "" && !isNewsLanguage(search.Language) {
+		slog.WarnContext(r.Context(), "Unsupported language", "lang", search.Language)
+		http.Error(w, "Unsupported language", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if search.Domains, err = parseDomainList(params.Get("domains")); err == nil {
+		search.ExcludeDomains, err = parseDomainList(params.Get("excludeDomains"))
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "Invalid domain list", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if search.SearchIn, err = parseSearchIn(params.Get("searchIn")); err != nil {
+		slog.WarnContext(r.Context(), "Invalid searchIn", "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	search.SortBy = canonicalSortOrder(params.Get("sortBy"))
+	if search.SortBy == "" {
+		search.SortBy = defaultSortBy
+	}
+	if !isSortOrder(search.SortBy) {
+		slog.WarnContext(r.Context(), "Unknown sortBy", "sort_by", search.SortBy)
+		http.Error(w, "Invalid sortBy: use relevancy, popularity or publishedAt", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if search.Category != "" && !isNewsCategory(search.Category) {
+		slog.WarnContext(r.Context(), "Unknown category", "category", search.Category)
+		http.Error(w, "Unknown category", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if headlines {
+		search.Country = strings.ToLower(params.Get("country"))
+		if search.Country == "" {
+			search.Country = defaultCountry
+		}
+		if !isNewsCountry(search.Country) {
+			slog.WarnContext(r.Context(), "Unsupported country", "country", search.Country)
+			http.Error(w, "Unsupported country", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+
+	if redirectToCanonical(w, r, search) {
+		return nil, false
+	}
+
+	// Call NewsAPI
+	var results Results
+	if headlines {
+		results, err = getTopHeadlines(r.Context(), search.Category, search.Country, pageSize, page)
+	} else {
+		results, err = searchNews(r.Context(), search.newsQuery(), pageSize, page)
+	}
+	if errors.Is(err, context.Canceled) {
+		slog.InfoContext(r.Context(), "Client went away before news arrived", "query", searchKey, "page", page, "error", err)
+		return nil, false
+	}
+	var rangeErr *pageRangeError
+	if errors.As(err, &rangeErr) {
+		if r.URL.Path == "/search/partial" {
+			w.WriteHeader(http.StatusNoContent) // Подгружать больше нечего, а редирект вернул бы последнюю страницу повторно
+			return nil, false
+		}
+		slog.InfoContext(r.Context(), "Page is beyond the last page, redirecting", "query", searchKey, "page", rangeErr.Page, "last_page", rangeErr.LastPage)
+		http.Redirect(w, r, r.URL.Path+"?"+search.query(rangeErr.LastPage).Encode(), http.StatusFound)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting news", "query", searchKey, "page", page, "error", err)
+		status, msg := newsErrorStatus(w, err)
+		http.Error(w, msg, status)
+		return nil, false
+	}
+
+	related := func([]Article) []Article { return nil }
+	if showRelated && !headlines && r.URL.Path == "/search" {
+		related = startRelated(r.Context(), search, results)
+	}
+
+	if collapseHeadlines {
+		before := len(results.Articles)
+		results.Articles = collapseConsecutive(results.Articles)
+		if removed := before - len(results.Articles); removed > 0 {
+			slog.InfoContext(r.Context(), "Collapsed repeated headlines", "removed", removed)
+		}
+	}
+	if removed := results.dedupe(); removed > 0 {
+		slog.InfoContext(r.Context(), "Removed duplicate articles", "removed", removed)
+	}
+	if !headlines && search.SortBy == "publishedAt" {
+		sortByPublishedDate(results.Articles)
+		if search.PinPopular {
+			// При сортировке по дате лучшие материалы тонут среди свежих заметок
+			results.Articles = pinTrusted(results.Articles, trustedSources)
+		}
+	}
+
+	// Закрепленные статьи показываем только на первой странице
+	results.Articles = applyPins(results.Articles, pins.Matching(searchKey), page == 1)
+	if search.TrustedOnly {
+		results.Articles = filterTrusted(results.Articles, trustedSources)
+	}
+	if search.Author != "" {
+		// NewsAPI не умеет искать по автору, поэтому фильтруем только полученную страницу
+		results.Articles = filterAuthor(results.Articles, search.Author)
+	}
+	if search.MaxAgeDays > 0 {
+		// /everything иногда отдает старые перепечатки даже при сортировке по дате
+		before := len(results.Articles)
+		results.Articles = filterMaxAge(results.Articles, time.Now().AddDate(0, 0, -search.MaxAgeDays))
+		if removed := before - len(results.Articles); removed > 0 {
+			slog.InfoContext(r.Context(), "Removed stale articles", "removed", removed, "max_age_days", search.MaxAgeDays)
+		}
+	}
+	markNewSince(results.Articles, previousVisit(r))
+	rememberArticles(results.Articles)
+	search.Results = results
+	search.Related = related(results.Articles)
+	if !headlines {
+		search.Facets = results.SourceFacets() // Для главных новостей фильтра по домену нет
+	}
+	search.NoResults = (searchKey != "" || headlines) && len(results.Articles) == 0
+	search.paginate(results.TotalResults, pageSize)
+	search.setMeta(r)
+	history.Add(searchKey)
+	auditSearch(r, newSearchAudit(search))
+
+	slog.InfoContext(r.Context(), "Search",
+		"query", search.SearchKey,
+		"page", search.CurrentPage,
+		"total_pages", search.TotalPages,
+		"previous_page", search.PreviousPage,
+		"next_page", search.NextPage,
+		"total_results", search.Results.TotalResults,
+	)
+	return search, true
 }
 
-// HasPreviousPage проверяет, есть ли предыдущая страница.
-func (s *Search) HasPreviousPage() bool {
-	return s.CurrentPage > 1
+// newsQuery — параметры поиска по /v2/everything помимо страницы.
+type newsQuery struct {
+	Query string
+	From  string // Дата в формате YYYY-MM-DD; пустая — без нижней границы
+	To    string // Дата в формате YYYY-MM-DD; пустая — без верхней границы
+
+	SortBy   string // relevancy, popularity или publishedAt; пустой — publishedAt
+	Language string // Двухбуквенный код языка статей; пустой — defaultLanguage
+
+	Domains        string // Домены через запятую, которыми ограничен поиск
+	ExcludeDomains string // Домены через запятую, исключенные из поиска
+	SearchIn       string // title, description, content через запятую; пустой — все поля
 }
 
-func (s *Search) HasNextPage() bool { // <-- Важно: HasNextPage с большой буквы
-	return s.CurrentPage < s.TotalPages
+// getNews делает запрос к NewsAPI и возвращает результаты.
+// Страницы нумеруются с 1: для page < 1 возвращается ErrInvalidPage,
+// для страницы за пределами выдачи — ErrPageOutOfRange.
+// Если запрос не уложился в срок, ошибка оборачивает ErrUpstreamTimeout.
+func getNews(ctx context.Context, q newsQuery, pageSize, page int) (Results, error) {
+	if page < 1 {
+		return Results{}, ErrInvalidPage
+	}
+
+	results, err := fetchNews(ctx, "everything", everythingParams(q), pageSize, page)
+	results.Articles = filterAllowedDomains(results.Articles)
+	return results, err
 }
 
-type Source struct {
-	ID   interface{} `json:"id"`
-	Name string      `json:"name"`
+// everythingParams — параметры /v2/everything для q без страницы и ключа.
+func everythingParams(q newsQuery) url.Values {
+	params := url.Values{}
+	params.Set("q", q.Query)
+	if q.From != "" {
+		params.Set("from", q.From)
+	}
+	if q.To != "" {
+		params.Set("to", q.To)
+	}
+	if domains := restrictDomains(q.Domains); domains != "" {
+		params.Set("domains", domains)
+	}
+	if q.ExcludeDomains != "" {
+		params.Set("excludeDomains", q.ExcludeDomains)
+	}
+	if q.SearchIn != "" {
+		params.Set("searchIn", q.SearchIn)
+	}
+	sortBy := q.SortBy
+	if sortBy == "" {
+		sortBy = defaultSortBy
+	}
+	params.Set("sortBy", sortBy)
+	language := q.Language
+	if language == "" {
+		language = defaultLanguage
+	}
+	params.Set("language", language)
+	return params
 }
 
-type Article struct {
-	Source      Source    `json:"source"`
-	Author      string    `json:"author"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	URL         string    `json:"url"`
-	URLToImage  string    `json:"urlToImage"`
-	PublishedAt time.Time `json:"publishedAt"`
-	Content     string    `json:"content"`
+// getTopHeadlines запрашивает главные новости страны country, при непустой
+// category — только из этой категории. Ошибки те же, что у getNews.
+func getTopHeadlines(ctx context.Context, category, country string, pageSize, page int) (Results, error) {
+	if page < 1 {
+		return Results{}, ErrInvalidPage
+	}
+
+	results, err := fetchNews(ctx, "top-headlines", topHeadlinesParams(category, country), pageSize, page)
+	results.Articles = filterAllowedDomains(results.Articles)
+	return results, err
 }
 
-// FormatPublishedDate форматирует дату публикации статьи.
-func (a *Article) FormatPublishedDate() string {
-	year, month, day := a.PublishedAt.Date()
-	return fmt.Sprintf("%v %d, %d", month, day, year)
+func topHeadlinesParams(category, country string) url.Values {
+	params := url.Values{}
+	params.Set("country", country)
+	if category != "" {
+		params.Set("category", category)
+	}
+	return params
 }
 
-type Results struct {
-	Status       string    `json:"status"`
-	TotalResults int       `json:"totalResults"`
-	Articles     []Article `json:"articles"`
+// resultsCacheKey — ключ resultsCache для страницы page метода path NewsAPI.
+// Дописывает в params pageSize и page.
+func resultsCacheKey(path string, params url.Values, pageSize, page int) string {
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	params.Set("page", strconv.Itoa(page))
+	return path + "?" + params.Encode() // Без ключа API
 }
 
-func indexHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("indexHandler called")
+// fetchNews выполняет запрос к методу path NewsAPI с учетом кэша и квоты.
+func fetchNews(ctx context.Context, path string, params url.Values, pageSize, page int) (Results, error) {
+	cacheKey := resultsCacheKey(path, params, pageSize, page)
 
-	// Создаем структуру Search с пустыми значениями
-	search := Search{
-		SearchKey:    "",        // Пустой поисковый запрос
-		CurrentPage:  1,         // Первая страница
-		TotalPages:   0,         // Нет результатов (пока)
-		PreviousPage: 0,         // Нет предыдущей страницы
-		NextPage:     0,         // Нет следующей страницы
-		Results:      Results{}, // Пустые результаты
+	if last := lastAllowedPage(resultLimit, pageSize); last > 0 && page > last {
+		// NewsAPI ответил бы maximumResultsReached. Запрашиваем последнюю
+		// доступную страницу: выдача может кончаться и раньше, а после
+		// редиректа страница возьмется из кэша.
+		results, err := fetchNews(ctx, path, params, pageSize, last)
+		var rangeErr *pageRangeError
+		if errors.As(err, &rangeErr) {
+			rangeErr.Page = page
+			return Results{}, rangeErr
+		}
+		if err != nil {
+			return Results{}, err
+		}
+		return Results{}, &pageRangeError{Page: page, LastPage: min(last, totalPages(results.TotalResults, pageSize))}
 	}
 
-	err := tpl.Execute(w, search) // Передаем структуру Search в шаблон
-	if err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+	if cached, ok := resultsCache.Get(cacheKey); ok {
+		slog.InfoContext(ctx, "Results cache hit", "key", cacheKey)
+		cacheLookups.Inc("results", "hit")
+		return cached.clone(), nil
 	}
-}
+	slog.InfoContext(ctx, "Results cache miss", "key", cacheKey)
+	cacheLookups.Inc("results", "miss")
 
-func searchHandler(w http.ResponseWriter, r *http.Request) {
-	// Parse URL and get parameters
-	u, err := url.Parse(r.URL.String())
+	resp, err := requestWithKeys(ctx, path, params)
 	if err != nil {
-		log.Printf("Error parsing URL: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+		countUpstreamError(err)
+		return Results{}, err
 	}
+	defer resp.Body.Close()
 
-	params := u.Query()
-	searchKey := params.Get("q")
-	pageStr := params.Get("page") // Get page as string
-	pageSize := 20                // Set page size
-
-	// Convert page string to integer
-	page := 1 // Default page
-	if pageStr != "" {
-		p, err := strconv.Atoi(pageStr)
+	var results Results
+	if err := decodeNewsAPIResponse(ctx, resp, &results); err != nil {
+		var apiErr *newsAPIError
+		if errors.As(err, &apiErr) {
+			return Results{}, resultLimitError(apiErr, page, pageSize)
+		}
+		return Results{}, err
+	}
+
+	results.TotalResults = clampTotalResults(results.TotalResults)
+	if page > totalPages(results.TotalResults, pageSize) {
+		return Results{}, &pageRangeError{Page: page, LastPage: totalPages(results.TotalResults, pageSize)}
+	}
+
+	resultsCache.Set(cacheKey, results.clone()) // Обработчики меняют Articles на месте
+
+	return results, nil
+}
+
+// requestWithKeys выполняет запрос с очередным ключом API. Ключ, получивший
+// 429 или исчерпавший квоту, откладывается на cooldown, и запрос повторяется
+// со следующим; когда ключи кончаются, возвращается ErrAllKeysExhausted.
+func requestWithKeys(ctx context.Context, path string, params url.Values) (*http.Response, error) {
+	for {
+		key, err := apiKeys.pick(time.Now())
 		if err != nil {
-			log.Printf("Error converting page to integer: %v", err)
-			http.Error(w, "Invalid page number", http.StatusBadRequest)
-			return
+			return nil, err
+		}
+
+		params.Set("apiKey", key)
+		endpoint := apiBaseURL + "/" + path + "?" + params.Encode()
+		slog.InfoContext(ctx, "Requesting NewsAPI", "url", redactURL(endpoint))
+
+		quota := apiKeys.quota(key)
+		resp, err := requestNews(ctx, endpoint, quota)
+		var until time.Time
+		switch {
+		case errors.Is(err, ErrQuotaExhausted) && apiKeys.Len() > 1:
+			until = quota.Status().Reset
+		case err != nil:
+			return nil, err
+		case resp.StatusCode != http.StatusTooManyRequests || apiKeys.Len() == 1:
+			// С одним ключом переключаться не на что: блокировка ключа на
+			// keyCooldown уронила бы весь сайт из-за короткого 429
+			return resp, nil
+		default:
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				until = time.Now().Add(d)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
 		}
-		page = p
+		if until.IsZero() {
+			until = time.Now().Add(keyCooldown)
+		}
+
+		apiKeys.markExhausted(key, until)
+		slog.WarnContext(ctx, "NewsAPI key is rate limited, rotating", "key", apiKeyHash(key), "until", until)
 	}
+}
 
-	// Create a Search struct
-	search := &Search{
-		SearchKey:   searchKey,
-		CurrentPage: page,
+// requestNews выполняет GET-запрос к NewsAPI. Ответы 429 и 5xx повторяются
+// до maxAttempts раз с экспоненциальной задержкой, для 429 учитывается
+// Retry-After. Запросы притормаживаются по квоте quota ключа из endpoint.
+// Возвращает последний ответ; закрыть его тело должен вызывающий.
+func requestNews(ctx context.Context, endpoint string, quota *quotaState) (*http.Response, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		wait, err := quota.throttle(time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if wait > 0 {
+			slog.WarnContext(ctx, "NewsAPI quota is low, delaying request", "delay", wait)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, upstreamError(err)
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("build request: %w", err)
+		}
+
+		start := time.Now()
+		resp, err := httpClient.Do(req)
+		observeNewsAPI(start)
+		if err != nil {
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				urlErr.URL = redactURL(urlErr.URL) // Текст *url.Error содержит полный адрес с ключом
+			}
+			slog.ErrorContext(ctx, "NewsAPI request failed", "error", err, "attempt", attempt, "latency_ms", time.Since(start).Milliseconds())
+			return nil, upstreamError(err)
+		}
+
+		quota.update(resp.Header, time.Now())
+		slog.InfoContext(ctx, "NewsAPI responded", "status_code", resp.StatusCode, "attempt", attempt, "latency_ms", time.Since(start).Milliseconds())
+
+		if resp.StatusCode == http.StatusTooManyRequests && apiKeys.Len() > 1 {
+			return resp, nil // Быстрее сменить ключ, чем ждать
+		}
+		if !isRetryableStatus(resp.StatusCode) || attempt >= maxAttempts {
+			return resp, nil
+		}
+
+		delay := backoff
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				delay = d
+			}
+		}
+		if delay > maxRetryDelay {
+			return resp, nil // Ждать так долго в рамках запроса пользователя нет смысла
+		}
+
+		io.Copy(io.Discard, resp.Body) // Чтобы соединение вернулось в пул
+		resp.Body.Close()
+
+		slog.WarnContext(ctx, "Retrying NewsAPI request", "status_code", resp.StatusCode, "attempt", attempt, "delay", delay)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, upstreamError(err)
+		}
+		backoff *= 2
 	}
+}
 
-	// Call NewsAPI
-	results, err := getNews(searchKey, pageSize, page)
+// parseAPIURL проверяет базовый адрес NewsAPI и убирает завершающий слэш.
+func parseAPIURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
 	if err != nil {
-		log.Printf("Error getting news: %v", err)
-		http.Error(w, "Failed to get news", http.StatusInternalServerError)
-		return
+		return "", err
 	}
-	if results.TotalResults == 0 {
-		search.Results.TotalResults = 0
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("%q must use http or https", raw)
 	}
-	search.Results = results
-	search.CurrentPage = page
+	if u.Host == "" {
+		return "", fmt.Errorf("%q has no host", raw)
+	}
+	return strings.TrimRight(raw, "/"), nil
+}
 
-	totalPages := 1
-	if results.TotalResults > 0 {
-		totalPages = int(math.Ceil(float64(results.TotalResults) / float64(pageSize)))
+// redactURL заменяет значение apiKey в адресе запроса на ****, чтобы ключ
+// не попадал в логи. Остальные параметры остаются как есть.
+func redactURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "<unparseable URL>"
+	}
+	params := u.Query()
+	if !params.Has("apiKey") {
+		return endpoint
+	}
+	params.Del("apiKey")
+	u.RawQuery = "apiKey=****"
+	if rest := params.Encode(); rest != "" {
+		u.RawQuery += "&" + rest
 	}
+	return u.String()
+}
 
-	search.TotalPages = totalPages // Переместите эту строку сюда!
+// isRetryableStatus сообщает, стоит ли повторить запрос с таким кодом ответа.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
 
-	if search.CurrentPage > 1 {
-		search.PreviousPage = search.CurrentPage - 1
-	} else {
-		search.PreviousPage = 0 // Нет предыдущей страницы
+// parseRetryAfter разбирает Retry-After в секундах или в виде HTTP-даты.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
 	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
 
-	if search.CurrentPage < search.TotalPages {
-		search.NextPage = search.CurrentPage + 1
-	} else {
-		search.NextPage = 0 // Нет следующей страницы
+// sleepContext ждет d или отмены ctx, смотря что наступит раньше.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newsErrorStatus подбирает HTTP-статус и сообщение для пользователя по ошибке
+// getNews: 400 для отвергнутого запроса, 429 для лимитов, 401 для ключа API,
+// 502 (504 для таймаута), если NewsAPI недоступен. Для лимитов заодно
+// выставляет заголовок Retry-After.
+func newsErrorStatus(w http.ResponseWriter, err error) (int, string) {
+	var apiErr *newsAPIError
+	switch {
+	case errors.As(err, &apiErr):
+		return newsAPIErrorStatus(apiErr)
+	case errors.Is(err, ErrInvalidPage):
+		return http.StatusBadRequest, "Invalid page number"
+	case errors.Is(err, ErrPageOutOfRange):
+		return http.StatusNotFound, "Page not found"
+	case errors.Is(err, ErrQuotaExhausted):
+		w.Header().Set("Retry-After", strconv.Itoa(apiKeys.quotaRetryAfter(time.Now())))
+		return http.StatusTooManyRequests, "News service is temporarily unavailable, try again later"
+	case errors.Is(err, ErrAllKeysExhausted):
+		w.Header().Set("Retry-After", strconv.Itoa(apiKeys.retryAfter(time.Now())))
+		return http.StatusTooManyRequests, "All NewsAPI keys are rate limited, try again later"
+	case errors.Is(err, ErrUpstreamTimeout):
+		return http.StatusGatewayTimeout, "News service did not respond in time"
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return http.StatusBadGateway, "News service is unavailable, try again later"
 	}
+	if status := errorKindStatus(err); status != http.StatusInternalServerError {
+		return status, "News service rejected the request"
+	}
+	return http.StatusInternalServerError, "Failed to get news"
+}
 
-	log.Printf("SearchKey: %s", search.SearchKey)
-	log.Printf("CurrentPage: %d", search.CurrentPage)
-	log.Printf("TotalPages: %d", search.TotalPages)
-	log.Printf("NextPage: %d", search.NextPage)
-	log.Printf("HasNextPage: %t", search.HasNextPage())
-	log.Printf("PreviousPage: %d", search.PreviousPage)
-	log.Printf("HasPreviousPage: %t", search.HasPreviousPage())
-	log.Printf("search.Results.TotalResults = %v (type %T)", search.Results.TotalResults, search.Results.TotalResults) // Логирование для проверки
-	err = tpl.Execute(w, search)
-	if err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+// canonicalSortOrder возвращает sortOrders-написание sortBy без учета регистра
+// ("publishedat" — "publishedAt"). Неизвестное значение возвращается как есть.
+func canonicalSortOrder(sortBy string) string {
+	for _, o := range sortOrders {
+		if strings.EqualFold(o, sortBy) {
+			return o
+		}
 	}
+	return sortBy
 }
 
-// getNews делает запрос к NewsAPI и возвращает результаты.
-func getNews(query string, pageSize, page int) (Results, error) {
-	endpoint := fmt.Sprintf("https://newsapi.org/v2/everything?q=%s&pageSize=%d&page=%d&apiKey=%s&sortBy=publishedAt&language=en", url.QueryEscape(query), pageSize, page, *apiKey)
-	log.Printf("Requesting URL: %s", endpoint) // Log the URL
+// isSortOrder сообщает, поддерживает ли NewsAPI такой порядок сортировки.
+func isSortOrder(sortBy string) bool {
+	for _, o := range sortOrders {
+		if o == sortBy {
+			return true
+		}
+	}
+	return false
+}
 
-	resp, err := http.Get(endpoint)
-	if err != nil {
-		log.Printf("HTTP Get error: %v", err) // Added logging
-		return Results{}, fmt.Errorf("HTTP Get error: %w", err)
+// isNewsLanguage сообщает, поддерживает ли NewsAPI такой язык статей.
+func isNewsLanguage(lang string) bool {
+	for _, l := range newsLanguages {
+		if l == lang {
+			return true
+		}
 	}
-	defer resp.Body.Close()
+	return false
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body) // Read the body for more info
-		log.Printf("API status code error: %d, body: %s", resp.StatusCode, string(body))
-		return Results{}, fmt.Errorf("API status code error: %d", resp.StatusCode) // More informative error
+// isNewsCountry сообщает, есть ли у NewsAPI главные новости этой страны.
+func isNewsCountry(country string) bool {
+	for _, c := range newsCountries {
+		if c == country {
+			return true
+		}
 	}
+	return false
+}
 
-	var results Results
-	err = json.NewDecoder(resp.Body).Decode(&results)
-	if err != nil {
-		log.Printf("JSON decode error: %v", err) // Added logging
-		return Results{}, fmt.Errorf("JSON decode error: %w", err)
+// isNewsCategory сообщает, поддерживает ли NewsAPI такую категорию.
+func isNewsCategory(category string) bool {
+	for _, c := range newsCategories {
+		if c == category {
+			return true
+		}
 	}
+	return false
+}
 
-	return results, nil
+// upstreamError оборачивает ошибку запроса к NewsAPI, отделяя истечение
+// срока (ErrUpstreamTimeout) от отмены клиентом и прочих сетевых ошибок.
+func upstreamError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %v", ErrUpstreamTimeout, err)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("request cancelled: %w", err)
+	}
+	return fmt.Errorf("%w: HTTP Get error: %w", ErrUpstreamUnavailable, err)
 }
 
 func main() {
+	// Смотрим до загрузки .env: ключи из -apikeyfile важнее .env, но не окружения
+	envKeys := os.Getenv("APIKEYS") != "" || os.Getenv("APIKEY") != ""
+
 	// Load .env file (if it exists)
 	err := godotenv.Load()
 	if err != nil {
@@ -211,34 +1964,315 @@ func main() {
 		port = "9000"
 	}
 
-	apiKey = flag.String("apikey", os.Getenv("APIKEY"), "Newsapi.org access key")
+	apiURL := os.Getenv("APIURL")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+
+	assets := os.Getenv("ASSETS_DIR")
+	if assets == "" {
+		assets = assetsDir
+	}
+
+	templateFile := os.Getenv("TEMPLATE")
+	if templateFile == "" {
+		templateFile = templatePath
+	}
+
+	configPath := flag.String("config", os.Getenv("CONFIG"), "JSON file with settings keyed by flag name; flags and env vars take precedence")
+	flag.StringVar(&port, "port", port, "Port to listen on")
+	flag.StringVar(&tlsCert, "tlscert", os.Getenv("TLS_CERT"), "PEM certificate file; with -tlskey serves HTTPS instead of HTTP")
+	flag.StringVar(&tlsKey, "tlskey", os.Getenv("TLS_KEY"), "PEM private key file for -tlscert")
+	flag.StringVar(&httpsRedirectAddr, "httpsredirect", "", "Address such as :80 for a plain HTTP listener that redirects to HTTPS; needs -tlscert")
+	flag.IntVar(&defaultPageSize, "pagesize", defaultPageSize, "Articles per page when the request does not ask for a page size")
+	flag.StringVar(&defaultLanguage, "language", defaultLanguage, "Article language when the request has no lang parameter")
+	keys := newKeyList(os.Getenv)
+	flag.Var(keys, "apikey", "Newsapi.org access key; repeat the flag or separate keys with commas to rotate on rate limits; defaults to $APIKEYS or $APIKEY")
+	keyFile := flag.String("apikeyfile", os.Getenv("APIKEY_FILE"), "File with the NewsAPI key, e.g. a Docker or Kubernetes secret; -apikey and $APIKEY take precedence, .env does not")
+	flag.StringVar(&apiBaseURL, "apiurl", apiURL, "Base URL of the NewsAPI v2 endpoints, e.g. a proxy or a test server")
+	flag.StringVar(&publicSiteURL, "siteurl", os.Getenv("SITE_URL"), "Public base URL such as https://news.example.com for the sitemap, RSS and canonical links")
+	flag.DurationVar(&keyCooldown, "keycooldown", keyCooldown, "How long to skip an API key after NewsAPI rate-limits it")
+	flag.BoolVar(&readerModeEnabled, "readermode", true, "Allow visitors to toggle the text-only reader mode")
+	flag.DurationVar(&httpClient.Timeout, "timeout", httpClient.Timeout, "Timeout for requests to NewsAPI")
+	flag.IntVar(&upstreamIdleConns, "upstreamidleconns", upstreamIdleConns, "Idle connections to NewsAPI kept open for reuse")
+	flag.DurationVar(&upstreamIdleTimeout, "upstreamidletimeout", upstreamIdleTimeout, "How long an idle connection to NewsAPI stays open")
+	flag.BoolVar(&upstreamCompression, "upstreamcompression", upstreamCompression, "Ask NewsAPI for gzip-compressed responses")
+	flag.IntVar(&maxAttempts, "retries", maxAttempts, "Attempts per NewsAPI request when it answers 429 or 5xx")
+	flag.DurationVar(&retryBackoff, "retrybackoff", retryBackoff, "Initial delay between NewsAPI retries, doubled on each attempt")
+	flag.BoolVar(&postRedirect, "postredirect", true, "Redirect POST /search to the equivalent GET URL instead of rendering directly")
+	flag.BoolVar(&validateProbe, "validateprobe", false, "Let /validate spend one NewsAPI request to estimate result counts")
+	flag.StringVar(&adminToken, "admintoken", os.Getenv("ADMIN_TOKEN"), "Shared token for /admin endpoints (empty disables them)")
+	pinsFile := flag.String("pins", "", "Path to a JSON file with articles pinned above matching results")
+	flag.IntVar(&quotaLow, "quotalow", quotaLow, "Slow down NewsAPI calls when the remaining quota of a key drops to this value")
+	flag.DurationVar(&quotaSlowDelay, "quotadelay", quotaSlowDelay, "Delay added before each NewsAPI call while the quota of its key is low")
+	flag.BoolVar(&collapseHeadlines, "collapseheadlines", true, "Collapse consecutive articles with the same title and source into the most recent one")
+	flag.BoolVar(&trackVisits, "sincelastvisit", true, "Mark articles published since the visitor's previous visit")
+	flag.IntVar(&maxRecentSearches, "recentsearches", maxRecentSearches, "How many of a visitor's recent searches to keep in a signed cookie (0 disables)")
+	secret := flag.String("cookiesecret", os.Getenv("COOKIE_SECRET"), "Secret for signing cookies; random on each start when empty")
+	flag.BoolVar(&prefetchNext, "prefetch", false, "Load the next results page into the cache in the background (one more NewsAPI request per page view)")
+	flag.BoolVar(&showRelated, "related", false, "Show related articles next to search results (one more NewsAPI request per search)")
+	flag.BoolVar(&trackClicks, "trackclicks", false, "Record clicks on article links via /out for analytics")
+	flag.IntVar(&exportWorkers, "exportworkers", exportWorkers, "Number of goroutines used to prepare articles for exports")
+	flag.StringVar(&imagePlaceholder, "imageplaceholder", imagePlaceholder, "Image shown for articles without a usable picture (empty shows none)")
+	tracking := flag.String("trackingparams", strings.Join(trackingParams, ","), "Query parameters stripped from article links, comma-separated; a trailing * matches a prefix")
+	locale := flag.String("locale", os.Getenv("LOCALE"), "Locale for publication dates: en-US, en-GB, de-DE, fr-FR or ru-RU (default en-US)")
+	flag.StringVar(&insecureImages, "insecureimages", "upgrade", "How to show http:// images on HTTPS pages: upgrade, hide or keep")
+	flag.IntVar(&pageWindowRadius, "pagewindow", pageWindowRadius, "How many page numbers to link on each side of the current page")
+	flag.BoolVar(&firstLastLinks, "firstlastlinks", firstLastLinks, "Link the first and last pages next to the page numbers")
+	maintenanceMode := flag.Bool("maintenance", false, "Start in maintenance mode: every page except health checks answers 503; toggle at runtime via /admin/maintenance")
+	flag.DurationVar(&maintenanceRetryAfter, "maintenanceretry", maintenanceRetryAfter, "Retry-After sent with maintenance pages")
+	flag.BoolVar(&strictParams, "strictparams", false, "Reject /search requests with unknown query parameters with 400")
+	flag.BoolVar(&canonicalRedirect, "canonicalredirect", false, "Redirect searches with non-canonical query parameters to the canonical URL with 301")
+	flag.StringVar(&pageAnchor, "pageanchor", "results", "Fragment that pagination links jump to (empty disables)")
+	flag.IntVar(&resultLimit, "resultlimit", resultLimit, "How many results the NewsAPI plan lets us page through (100 on the free Developer plan, 0 for no limit)")
+	flag.IntVar(&maxTotalResults, "maxtotalresults", maxTotalResults, "Clamp the reported total results to this value before paginating (0 disables)")
+	trusted := flag.String("trustedsources", os.Getenv("TRUSTED_SOURCES"), "Comma-separated NewsAPI source ids or domains shown as verified")
+	allowed := flag.String("alloweddomains", os.Getenv("ALLOWED_DOMAINS"), "Comma-separated domains the site is limited to (empty allows all)")
+	auditPath := flag.String("auditlog", os.Getenv("AUDIT_LOG"), "File for the JSON search audit log (\"-\" for stdout, empty disables)")
+	flag.BoolVar(&auditHashIP, "audithaship", true, "Hash client IPs in the audit log with an HMAC keyed by -cookiesecret")
+	cors := flag.String("corsorigin", "*", "Comma-separated origins allowed to call the JSON API from browsers (\"*\" for any, empty disables CORS)")
+	rateLimit := flag.Int("ratelimit", 60, "Requests per minute allowed from one client IP (0 disables)")
+	flag.BoolVar(&trustProxy, "trustproxy", false, "Take the client IP from X-Forwarded-For set by our reverse proxy")
+	flag.StringVar(&assetsDir, "assets", assets, "Directory with static files served under /assets/")
+	flag.StringVar(&templatePath, "template", templateFile, "Path to index.html; the other page templates are read from the same directory")
+	flag.BoolVar(&allowCrawling, "allowcrawling", allowCrawling, "Let search engines crawl the site; false makes robots.txt disallow everything")
+	flag.BoolVar(&devMode, "dev", false, "Re-read the HTML templates on every request and disable the page cache")
+	flag.DurationVar(&assetMaxAge, "assetmaxage", assetMaxAge, "Cache-Control max-age for /assets/ files; fingerprinted names get a year and immutable")
+	flag.DurationVar(&pageMaxAge, "pagemaxage", pageMaxAge, "Cache-Control max-age for search pages, private to the browser (0 means no-cache)")
+	pageCacheTTL := flag.Duration("pagecachettl", 30*time.Second, "How long rendered search pages are cached (0 disables)")
+	cacheTTL := flag.Duration("cachettl", 5*time.Minute, "How long NewsAPI responses are cached (0 disables)")
+	cacheSize := flag.Int("cachesize", 1000, "Maximum number of cached NewsAPI responses")
+	noCache := flag.Bool("nocache", false, "Bypass the NewsAPI response cache")
+	logJSON := flag.Bool("logjson", false, "Write logs as structured JSON lines")
+	flag.DurationVar(&homeRefresh, "homerefresh", 0, "How often to refresh top headlines shown on the homepage in the background; 0 keeps the homepage without headlines")
+	shutdownTimeout := flag.Duration("shutdowntimeout", 15*time.Second, "How long to wait for in-flight requests on shutdown")
+	historySize := flag.Int("historysize", 1000, "Maximum number of search history entries retained")
+	historyTTL := flag.Duration("historyttl", 7*24*time.Hour, "How long a search history entry is retained")
+	showVersion := flag.Bool("version", false, "Print the version, commit and build date, then exit")
 	flag.Parse()
 
-	if *apiKey == "" {
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	if err := applyConfigFile(flag.CommandLine, *configPath, os.Getenv); err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	if defaultPageSize < 1 || defaultPageSize > maxPageSize {
+		log.Fatalf("Invalid -pagesize value %d: use 1..%d", defaultPageSize, maxPageSize)
+	}
+	if !isNewsLanguage(defaultLanguage) {
+		log.Fatalf("Invalid -language value %q", defaultLanguage)
+	}
+
+	switch insecureImages {
+	case "upgrade", "hide", "keep":
+	default:
+		log.Fatalf("Invalid -insecureimages value %q: use upgrade, hide or keep", insecureImages)
+	}
+
+	corsOrigins = parseOrigins(*cors)
+
+	cookieSecret = []byte(*secret)
+	if len(cookieSecret) == 0 {
+		log.Println("No -cookiesecret set, recent searches will be forgotten on restart")
+		cookieSecret = newCookieSecret()
+	}
+
+	var limiter *rateLimiter
+	if *rateLimit > 0 {
+		limiter = newRateLimiter(*rateLimit)
+	}
+
+	httpClient.Transport = newNewsTransport(upstreamIdleConns, upstreamIdleTimeout, upstreamCompression)
+
+	apiBaseURL, err = parseAPIURL(apiBaseURL)
+	if err != nil {
+		log.Fatalf("Invalid -apiurl value: %v", err)
+	}
+	if publicSiteURL != "" {
+		if publicSiteURL, err = parseAPIURL(publicSiteURL); err != nil {
+			log.Fatalf("Invalid -siteurl value: %v", err)
+		}
+	} else if !devMode {
+		log.Print("-siteurl is not set: sitemap.xml is disabled, canonical links are relative and RSS feeds have no channel link")
+	}
+
+	if err := checkTLSConfig(tlsCert, tlsKey, httpsRedirectAddr); err != nil {
+		log.Fatalf("Invalid TLS settings: %v", err)
+	}
+
+	trustedSources = parseTrustSet(*trusted)
+	trackingParams = parseTrackingParams(*tracking)
+	maintenance.Store(*maintenanceMode)
+
+	if publishedDateLocale, err = parseDateLocale(*locale); err != nil {
+		log.Fatalf("Invalid -locale value: %v", err)
+	}
+
+	domains, err := parseDomainList(*allowed)
+	if err != nil {
+		log.Fatalf("Invalid -alloweddomains value: %v", err)
+	}
+	if domains != "" {
+		allowedDomains = strings.Split(domains, ",")
+	}
+
+	auditLogger, err = openAuditLog(*auditPath)
+	if err != nil {
+		log.Fatalf("Error opening audit log: %v", err)
+	}
+
+	history = newSearchHistory(*historySize, *historyTTL)
+	if devMode {
+		*pageCacheTTL = 0 // Иначе правки шаблона видны только после истечения кэша
+	}
+	pageCache = newTTLCache[renderedPage](*pageCacheTTL, 500)
+	resultsCache = newTTLCache[Results](*cacheTTL, *cacheSize)
+	if *noCache {
+		resultsCache = newTTLCache[Results](0, 0)
+	}
+
+	pins, err = loadPins(*pinsFile)
+	if err != nil {
+		log.Fatalf("Error loading pinned articles: %v", err)
+	}
+
+	if *logJSON {
+		slog.SetDefault(slog.New(requestIDHandler{slog.NewJSONHandler(os.Stderr, nil)})) // log.Printf тоже идет через этот обработчик
+	} else {
+		// Обертка над стандартным обработчиком slog: SetDefault направил бы
+		// пакет log в slog, а стандартный обработчик сам пишет через log, поэтому
+		// log возвращается прямо в stderr.
+		slog.SetDefault(slog.New(requestIDHandler{slog.Default().Handler()}))
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}
+	slog.Info("Starting " + versionString())
+	if maintenance.Load() {
+		slog.Info("Starting in maintenance mode")
+	}
+	if len(allowedDomains) > 0 {
+		slog.Info("Restricting articles to allowed domains", "domains", strings.Join(allowedDomains, ","))
+	}
+
+	if *keyFile != "" {
+		if err := keys.useKeyFile(*keyFile, envKeys); err != nil {
+			log.Fatalf("Cannot read -apikeyfile: %v", err)
+		}
+	}
+	if len(keys.keys) == 0 {
 		log.Fatal("apiKey must be set") // Fatal: if no apiKey is provided
 	}
+	apiKeys = newKeyRing(keys.keys)
+
+	log.Printf("Using API keys: %s (last 4 digits)", keys) // Добавил вывод для API key
 
-	log.Printf("Using API key: %s (last 4 digits)", apiKeyHash(*apiKey)) // Добавил вывод для API key
+	if info, err := os.Stat(assetsDir); err != nil || !info.IsDir() {
+		log.Fatalf("Assets directory %q not found: set -assets or $ASSETS_DIR", assetsDir)
+	}
+	if _, err := os.Stat(templatePath); err != nil {
+		log.Fatalf("Template %q not found: set -template or $TEMPLATE", templatePath)
+	}
 
 	// Загрузка и парсинг шаблона (теперь с проверкой на ошибки)
-	tpl, err = template.ParseFiles("index.html")
-	if err != nil {
+	if err := loadTemplates(); err != nil {
 		log.Fatalf("Error parsing template: %v", err) // Fatal error: приложение не может работать без шаблона
 	}
 
 	mux := http.NewServeMux()
 
-	fs := http.FileServer(http.Dir("assets"))
-	mux.Handle("/assets/", http.StripPrefix("/assets/", fs))
+	fs := http.FileServer(http.Dir(assetsDir))
+	mux.Handle("/assets/", http.StripPrefix("/assets/", cacheAssets(fs)))
 
+	mux.HandleFunc("/favicon.ico", faviconHandler)
+	mux.HandleFunc("/robots.txt", robotsHandler)
+	mux.HandleFunc("/sitemap.xml", sitemapHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
 	mux.HandleFunc("/search", searchHandler)
+	mux.HandleFunc("/search/partial", partialSearchHandler)
+	mux.HandleFunc("/headlines", headlinesHandler)
+	mux.Handle("/api/search", allowCORS(http.HandlerFunc(apiSearchHandler)))
+	mux.HandleFunc("/export.json", exportHandler)
+	mux.HandleFunc("/export", csvExportHandler)
+	mux.HandleFunc("/feed", feedHandler)
+	mux.HandleFunc("/article", articleHandler)
+	mux.Handle("/suggest", allowCORS(http.HandlerFunc(suggestHandler)))
+	mux.Handle("/sources", allowCORS(http.HandlerFunc(sourcesHandler)))
+	mux.HandleFunc("/metrics", metricsHandler)
+	mux.HandleFunc("/stats", statsHandler)
+	mux.HandleFunc("/reader", readerModeHandler)
+	mux.HandleFunc("/theme", themeHandler)
+	mux.HandleFunc("/bookmark", bookmarkHandler)
+	mux.HandleFunc("/bookmarks", bookmarksHandler)
+	mux.HandleFunc("/validate", validateHandler)
+	mux.HandleFunc("/out", outHandler)
+	mux.HandleFunc("/admin/pins", adminPinsHandler)
+	mux.HandleFunc("/admin/stats", adminStatsHandler)
+	mux.HandleFunc("/admin/maintenance", adminMaintenanceHandler)
 	mux.HandleFunc("/", indexHandler)
 
-	log.Printf("Server listening on port %s", port)
-	err = http.ListenAndServe(":"+port, mux)
-	if err != nil {
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: withRequestID(logRequests(countRequests(mux, gzipResponses(limitRequests(limiter, serveMaintenance(recoverPanics(mux))))))),
+	}
+
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	refreshDone := make(chan struct{})
+	if homeRefresh > 0 {
+		go func() {
+			defer close(refreshDone)
+			home.refresh(refreshCtx, homeRefresh, fetchHomeHeadlines)
+		}()
+	} else {
+		close(refreshDone)
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if tlsCert != "" {
+			log.Printf("Server listening with HTTPS on port %s", port)
+			serveErr <- srv.ListenAndServeTLS(tlsCert, tlsKey)
+			return
+		}
+		log.Printf("Server listening on port %s", port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var redirectSrv *http.Server
+	if httpsRedirectAddr != "" {
+		redirectSrv = &http.Server{Addr: httpsRedirectAddr, Handler: httpsRedirectHandler(port)}
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", httpsRedirectAddr)
+			serveErr <- redirectSrv.ListenAndServe()
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
 		log.Fatal("ListenAndServe error: ", err)
+	case sig := <-stop:
+		log.Printf("Received %v, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	stopRefresh()
+	<-refreshDone
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx) // Перенаправлениям дожидаться нечего
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown error: %v", err)
+		os.Exit(1)
 	}
+	log.Println("Server stopped")
 }
 
 func apiKeyHash(key string) string {
@@ -0,0 +1,1073 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// articlesJSON — ответ /v2/everything с total результатами и count статьями.
+func articlesJSON(total, count int) string {
+	articles := make([]string, count)
+	for i := range articles {
+		articles[i] = fmt.Sprintf(`{"source":{"id":"src","name":"Source %d"},"author":"Author","title":"Article %d","description":"About %d","url":"https://example.com/%d","publishedAt":"2024-05-01T10:00:00Z"}`, i, i, i, i)
+	}
+	return fmt.Sprintf(`{"status":"ok","totalResults":%d,"articles":[%s]}`, total, strings.Join(articles, ","))
+}
+
+// newMockNewsAPI поднимает подставной NewsAPI и направляет на него запросы.
+// Кэши и повторы отключаются, чтобы каждый запрос доходил до handler.
+func newMockNewsAPI(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+
+	oldURL, oldKeys, oldAttempts := apiBaseURL, apiKeys, maxAttempts
+	oldResults, oldPages := resultsCache, pageCache
+	apiBaseURL = srv.URL + "/v2"
+	apiKeys = newKeyRing([]string{"test-key"})
+	maxAttempts = 1
+	resultsCache = newTTLCache[Results](0, 0)
+	pageCache = newTTLCache[renderedPage](0, 0)
+	t.Cleanup(func() {
+		srv.Close()
+		apiBaseURL, apiKeys, maxAttempts = oldURL, oldKeys, oldAttempts
+		resultsCache, pageCache = oldResults, oldPages
+	})
+
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	return srv
+}
+
+// useIndexTemplate разбирает index.html для тестов, которые рендерят страницы.
+func useIndexTemplate(t *testing.T) {
+	t.Helper()
+	index, err := template.ParseFiles("index.html")
+	if err != nil {
+		t.Fatalf("ParseFiles: %v", err)
+	}
+	tpl = index
+}
+
+// stubNewsAPI поднимает подставной NewsAPI, который на любой запрос отвечает
+// телом body, и возвращает счетчик обращений к нему.
+func stubNewsAPI(t *testing.T, body string) *atomic.Int32 {
+	t.Helper()
+	var calls atomic.Int32
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	})
+	return &calls
+}
+
+func TestSearchHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		status     int
+		body       string // Тело ответа NewsAPI
+		apiStatus  int
+		wantInBody string
+	}{
+		{"results", "/search?q=golang", http.StatusOK, articlesJSON(45, 20), http.StatusOK, "Article 0"},
+		{"no results", "/search?q=nothing", http.StatusOK, articlesJSON(0, 0), http.StatusOK, `class="no-results"`},
+		{"invalid page", "/search?q=golang&page=abc", http.StatusBadRequest, articlesJSON(45, 20), http.StatusOK, "Invalid page number"},
+		{"zero page", "/search?q=golang&page=0", http.StatusBadRequest, articlesJSON(45, 20), http.StatusOK, "Invalid page number"},
+		{"upstream error", "/search?q=golang", http.StatusBadGateway, `{"status":"error"`, http.StatusInternalServerError, "News service is unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.apiStatus)
+				fmt.Fprint(w, tt.body)
+			})
+
+			rec := httptest.NewRecorder()
+			searchHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.status, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantInBody) {
+				t.Errorf("body does not contain %q:\n%s", tt.wantInBody, rec.Body)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerPageOutOfRangeRedirects(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 5))
+	})
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&page=9", nil))
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if got := loc.Query().Get("page"); got != "3" {
+		t.Errorf("redirect page = %q, want 3", got)
+	}
+}
+
+func TestRunSearchPagination(t *testing.T) {
+	var gotQuery url.Values
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query()
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+
+	tests := []struct {
+		page         string
+		totalPages   int
+		previousPage int
+		hasNext      bool
+	}{
+		{"1", 3, 0, true},
+		{"2", 3, 1, true},
+		{"3", 3, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run("page "+tt.page, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			search, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&page="+tt.page, nil), false)
+			if !ok {
+				t.Fatalf("runSearch failed: %d %s", rec.Code, rec.Body)
+			}
+
+			if search.TotalPages != tt.totalPages {
+				t.Errorf("TotalPages = %d, want %d", search.TotalPages, tt.totalPages)
+			}
+			if search.PreviousPage != tt.previousPage {
+				t.Errorf("PreviousPage = %d, want %d", search.PreviousPage, tt.previousPage)
+			}
+			if search.HasNextPage() != tt.hasNext {
+				t.Errorf("HasNextPage() = %t, want %t", search.HasNextPage(), tt.hasNext)
+			}
+			if got := gotQuery.Get("page"); got != tt.page {
+				t.Errorf("NewsAPI page = %q, want %q", got, tt.page)
+			}
+			if got := gotQuery.Get("apiKey"); got != "test-key" {
+				t.Errorf("NewsAPI apiKey = %q, want test-key", got)
+			}
+		})
+	}
+}
+
+func TestRunSearchUsesInjectedNews(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	old := searchNews
+	t.Cleanup(func() { searchNews = old })
+
+	var got newsQuery
+	searchNews = func(ctx context.Context, q newsQuery, pageSize, page int) (Results, error) {
+		got = q
+		return Results{Status: "ok", TotalResults: 1, Articles: []Article{{Title: "Stub", URL: "https://example.com/stub"}}}, nil
+	}
+
+	rec := httptest.NewRecorder()
+	search, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=mars&lang=de&sortBy=popularity", nil), false)
+	if !ok {
+		t.Fatalf("runSearch failed: %d %s", rec.Code, rec.Body)
+	}
+	if got.Query != "mars" || got.Language != "de" || got.SortBy != "popularity" {
+		t.Errorf("newsQuery = %+v, want q=mars lang=de sortBy=popularity", got)
+	}
+	if len(search.Results.Articles) != 1 || search.Results.Articles[0].Title != "Stub" {
+		t.Errorf("Articles = %+v, want the stubbed article", search.Results.Articles)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	search := &Search{
+		SearchKey:   "golang",
+		CurrentPage: 2,
+		TotalPages:  3,
+		Results: Results{
+			Status:       "ok",
+			TotalResults: 45,
+			Articles: []Article{{
+				Source:      Source{Name: "Example"},
+				Title:       "Go 1.22 released",
+				URL:         "https://example.com/go",
+				PublishedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			}},
+		},
+	}
+	snapshot := Snapshot{GeneratedAt: time.Date(2024, 5, 2, 8, 30, 0, 0, time.UTC), Search: search}
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var loaded Snapshot
+	if err := json.Unmarshal(body, &loaded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if !loaded.GeneratedAt.Equal(snapshot.GeneratedAt) {
+		t.Errorf("GeneratedAt = %v, want %v", loaded.GeneratedAt, snapshot.GeneratedAt)
+	}
+	got := loaded.Search
+	if got == nil {
+		t.Fatal("Search is missing from the snapshot")
+	}
+	if got.SearchKey != "golang" || got.CurrentPage != 2 || got.TotalPages != 3 {
+		t.Errorf("Search = %q page %d/%d, want \"golang\" page 2/3", got.SearchKey, got.CurrentPage, got.TotalPages)
+	}
+	if got.Results.TotalResults != 45 || len(got.Results.Articles) != 1 {
+		t.Fatalf("Results = %d total, %d articles, want 45 and 1", got.Results.TotalResults, len(got.Results.Articles))
+	}
+	a := got.Results.Articles[0]
+	if a.Title != "Go 1.22 released" || a.Source.Name != "Example" || !a.PublishedAt.Equal(search.Results.Articles[0].PublishedAt) {
+		t.Errorf("article = %+v", a)
+	}
+}
+
+func TestSearchPaginate(t *testing.T) {
+	tests := []struct {
+		name                 string
+		total, size, current int
+		pages, prev, next    int
+	}{
+		{"no results", 0, 20, 1, 1, 0, 0},
+		{"single page", 5, 20, 1, 1, 0, 0},
+		{"exact fit", 40, 20, 2, 2, 1, 0},
+		{"first page", 45, 20, 1, 3, 0, 2},
+		{"middle page", 45, 20, 2, 3, 1, 3},
+		{"last page", 45, 20, 3, 3, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Search{CurrentPage: tt.current}
+			s.paginate(tt.total, tt.size)
+			if s.TotalPages != tt.pages || s.PreviousPage != tt.prev || s.NextPage != tt.next {
+				t.Errorf("paginate(%d, %d) on page %d = %d pages, prev %d, next %d; want %d, %d, %d",
+					tt.total, tt.size, tt.current, s.TotalPages, s.PreviousPage, s.NextPage, tt.pages, tt.prev, tt.next)
+			}
+			if s.HasPreviousPage() != (tt.prev > 0) || s.HasNextPage() != (tt.next > 0) {
+				t.Errorf("HasPreviousPage = %t, HasNextPage = %t on page %d of %d", s.HasPreviousPage(), s.HasNextPage(), tt.current, s.TotalPages)
+			}
+		})
+	}
+}
+
+func TestReaderModeCookieRoundTrip(t *testing.T) {
+	readerModeEnabled = true
+	useIndexTemplate(t)
+
+	rec := httptest.NewRecorder()
+	readerModeHandler(rec, httptest.NewRequest(http.MethodGet, "/reader?mode=on", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != readerModeCookie || cookies[0].Value != "on" {
+		t.Fatalf("cookies = %v, want %s=on", cookies, readerModeCookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	if !readerMode(req) {
+		t.Fatal("readerMode = false after the cookie was set")
+	}
+	rec = httptest.NewRecorder()
+	indexHandler(rec, req)
+	if !strings.Contains(rec.Body.String(), `<body class="reader-mode">`) {
+		t.Errorf("body class reader-mode missing from the page")
+	}
+
+	rec = httptest.NewRecorder()
+	indexHandler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `<body class="">`) {
+		t.Errorf("reader-mode class rendered without the cookie")
+	}
+
+	rec = httptest.NewRecorder()
+	readerModeHandler(rec, httptest.NewRequest(http.MethodGet, "/reader?mode=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid mode: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestArticleMissingPublishedAt(t *testing.T) {
+	body := `{"articles":[
+		{"title":"no date","publishedAt":""},
+		{"title":"old","publishedAt":"2024-04-01T10:00:00Z"},
+		{"title":"garbage","publishedAt":"yesterday"},
+		{"title":"new","publishedAt":"2024-05-01T10:00:00Z"}
+	]}`
+	var results Results
+	if err := json.Unmarshal([]byte(body), &results); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	sortByPublishedDate(results.Articles)
+	var order []string
+	for _, a := range results.Articles {
+		order = append(order, a.Title)
+	}
+	if strings.Join(order, ",") != "new,old,no date,garbage" {
+		t.Errorf("order = %v, want dated articles first, newest first", order)
+	}
+
+	undated := results.Articles[2]
+	if undated.HasPublishedDate() {
+		t.Errorf("HasPublishedDate = true for %q", undated.Title)
+	}
+	if got := undated.FormatPublishedDate(); got != "" {
+		t.Errorf("FormatPublishedDate = %q, want empty (the template shows date.unknown)", got)
+	}
+	if got := results.Articles[0].FormatPublishedDate(); got != "May 1, 2024" {
+		t.Errorf("FormatPublishedDate = %q, want \"May 1, 2024\"", got)
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		probe     bool
+		q         string
+		valid     bool
+		estimated int // -1 — оценки в ответе нет
+		calls     int32
+	}{
+		{"valid without probe", false, "golang", true, -1, 0},
+		{"invalid without probe", false, `"golang`, false, -1, 0},
+		{"valid with probe", true, "golang", true, 1234, 1},
+		{"invalid query skips probe", true, "golang AND", false, -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := stubNewsAPI(t, `{"status":"ok","totalResults":1234,"articles":[]}`)
+			validateProbe = tt.probe
+			defer func() { validateProbe = false }()
+
+			rec := httptest.NewRecorder()
+			validateHandler(rec, httptest.NewRequest(http.MethodGet, "/validate?q="+url.QueryEscape(tt.q), nil))
+
+			var got QueryValidation
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got.IsValid != tt.valid || got.IsValid != (len(got.Messages) == 0) {
+				t.Errorf("isValid = %t with messages %v, want %t", got.IsValid, got.Messages, tt.valid)
+			}
+			switch {
+			case tt.estimated < 0 && got.EstimatedResults != nil:
+				t.Errorf("estimatedResults = %d, want none", *got.EstimatedResults)
+			case tt.estimated >= 0 && (got.EstimatedResults == nil || *got.EstimatedResults != tt.estimated):
+				t.Errorf("estimatedResults = %v, want %d", got.EstimatedResults, tt.estimated)
+			}
+			if calls.Load() != tt.calls {
+				t.Errorf("NewsAPI calls = %d, want %d", calls.Load(), tt.calls)
+			}
+		})
+	}
+}
+
+func TestSearchHandlerPost(t *testing.T) {
+	defer func() { postRedirect = true }()
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/search", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		searchHandler(rec, req)
+		return rec
+	}
+
+	postRedirect = true
+	rec := post(url.Values{"q": {"climate change"}, "page": {"2"}, "empty": {""}})
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/search?page=2&q=climate+change" {
+		t.Errorf("Location = %q, want /search?page=2&q=climate+change", loc)
+	}
+	if rec := post(url.Values{"q": {"go"}, "page": {"two"}}); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid page: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	postRedirect = false
+	calls := stubNewsAPI(t, `{"status":"ok","totalResults":1,"articles":[{"title":"Posted result","url":"https://example.com/1","publishedAt":"2024-05-01T10:00:00Z"}]}`)
+	rec = post(url.Values{"q": {"go"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Posted result") {
+		t.Errorf("direct POST: status = %d, want 200 with the results page", rec.Code)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("NewsAPI calls = %d, want 1", calls.Load())
+	}
+
+	rec = httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodPut, "/search?q=go", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("PUT: status = %d, Allow = %q", rec.Code, rec.Header().Get("Allow"))
+	}
+}
+
+func TestSearchHandlerPageCache(t *testing.T) {
+	stubNewsAPI(t, `{"status":"ok","totalResults":7,"articles":[]}`)
+	renders := 0
+	oldTpl := tpl
+	defer func() { tpl = oldTpl }()
+	tpl = template.Must(template.New("index").Funcs(template.FuncMap{
+		"rendered": func() string { renders++; return "" },
+	}).Parse(`{{ rendered }}{{ .SearchKey }}: {{ .Results.TotalResults }}`))
+	pageCache = newTTLCache[renderedPage](time.Minute, 100) // newMockNewsAPI вернет прежний кэш
+
+	get := func(target, acceptLanguage string) string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		rec := httptest.NewRecorder()
+		searchHandler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	first := get("/search?q=cached", "en")
+	second := get("/search?q=cached", "en")
+	if renders != 1 {
+		t.Errorf("renders = %d after two identical requests, want 1", renders)
+	}
+	if first != second || first != "cached: 7" {
+		t.Errorf("cached page = %q, first render = %q", second, first)
+	}
+
+	get("/search?q=cached", "ru")
+	get("/search?q=cached&page=1", "en")
+	if renders != 3 {
+		t.Errorf("renders = %d, want another render per locale and parameter set", renders)
+	}
+}
+
+func TestCollapseConsecutive(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 5, 1, hour, 0, 0, 0, time.UTC) }
+	articles := []Article{
+		{Title: "Breaking", Source: Source{Name: "Wire"}, URL: "1", PublishedAt: at(9)},
+		{Title: "Breaking", Source: Source{Name: "Wire"}, URL: "2", PublishedAt: at(11)},
+		{Title: "Breaking", Source: Source{Name: "Wire"}, URL: "3", PublishedAt: at(10)},
+		{Title: "Breaking", Source: Source{Name: "Daily"}, URL: "4", PublishedAt: at(8)},
+		{Title: "Other", Source: Source{Name: "Wire"}, URL: "5", PublishedAt: at(7)},
+		{Title: "Breaking", Source: Source{Name: "Wire"}, URL: "6", PublishedAt: at(6)},
+	}
+
+	var urls []string
+	for _, a := range collapseConsecutive(articles) {
+		urls = append(urls, a.URL)
+	}
+	// Подряд идущие 1–3 схлопываются в самую свежую (2); другой источник
+	// и неподряд идущий дубль (6) остаются.
+	if got := strings.Join(urls, ","); got != "2,4,5,6" {
+		t.Errorf("collapseConsecutive = %s, want 2,4,5,6", got)
+	}
+}
+
+func TestDisplayImageURL(t *testing.T) {
+	defer func() { insecureImages = "upgrade" }()
+	tests := []struct {
+		policy, image string
+		secure        bool
+		want          string
+	}{
+		{"upgrade", "http://img.example/a.jpg", true, "https://img.example/a.jpg"},
+		{"upgrade", "HTTP://img.example/a.jpg", true, "https://img.example/a.jpg"},
+		{"upgrade", "http://img.example/a.jpg", false, "http://img.example/a.jpg"},
+		{"hide", "http://img.example/a.jpg", true, imagePlaceholder},
+		{"hide", "https://img.example/a.jpg", true, "https://img.example/a.jpg"},
+		{"keep", "http://img.example/a.jpg", true, "http://img.example/a.jpg"},
+		{"hide", "", true, imagePlaceholder},
+	}
+	for _, tt := range tests {
+		insecureImages = tt.policy
+		a := &Article{URLToImage: tt.image}
+		if got := a.DisplayImageURL(tt.secure); got != tt.want {
+			t.Errorf("%s: DisplayImageURL(%q, secure=%t) = %q, want %q", tt.policy, tt.image, tt.secure, got, tt.want)
+		}
+	}
+}
+
+func TestIsSecureRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if isSecureRequest(r) {
+		t.Error("plain HTTP request reported as secure")
+	}
+	r.Header.Set("X-Forwarded-Proto", "HTTPS")
+	if !isSecureRequest(r) {
+		t.Error("request forwarded over HTTPS reported as insecure")
+	}
+	if !isSecureRequest(httptest.NewRequest(http.MethodGet, "https://example.com/", nil)) {
+		t.Error("TLS request reported as insecure")
+	}
+}
+
+// pageLinkPattern находит ссылки пагинации на странице результатов.
+var pageLinkPattern = regexp.MustCompile(`href="(/search\?[^"]*page=[^"]*)"`)
+
+func TestPaginationLinksKeepAnchor(t *testing.T) {
+	useIndexTemplate(t)
+	defer func() { pageAnchor = "results" }()
+
+	render := func(anchor string) string {
+		pageAnchor = anchor
+		search := &Search{SearchKey: "go", CurrentPage: 2, Locale: defaultLocale, PageAnchor: pageAnchor}
+		search.Results = Results{TotalResults: 60, Articles: []Article{{Title: "Story", URL: "https://example.com/1"}}}
+		search.paginate(search.Results.TotalResults, 20)
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, search); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		return buf.String()
+	}
+
+	page := render("results")
+	if !strings.Contains(page, `id="results"`) {
+		t.Error(`results section has no id="results"`)
+	}
+	links := pageLinkPattern.FindAllStringSubmatch(page, -1)
+	if len(links) < 2 {
+		t.Fatalf("found %d pagination links, want previous and next", len(links))
+	}
+	for _, link := range links {
+		if !strings.HasSuffix(link[1], "#results") {
+			t.Errorf("pagination link %q does not end with #results", link[1])
+		}
+	}
+
+	if page := render(""); strings.Contains(page, "#results") || strings.Contains(page, `id="results"`) {
+		t.Error("anchor rendered with -pageanchor disabled")
+	}
+}
+
+func TestHugeTotalResults(t *testing.T) {
+	oldLimit := resultLimit
+	resultLimit = 0 // Ограничение тарифа проверяется отдельно
+	defer func() { maxTotalResults, resultLimit = 100000, oldLimit }()
+
+	maxTotalResults = 100000
+	if got := clampTotalResults(math.MaxInt); got != 100000 {
+		t.Errorf("clampTotalResults(MaxInt) = %d, want 100000", got)
+	}
+	if got := clampTotalResults(450); got != 450 {
+		t.Errorf("clampTotalResults(450) = %d, want 450", got)
+	}
+	s := &Search{CurrentPage: 1}
+	s.paginate(clampTotalResults(math.MaxInt), 20)
+	if s.TotalPages != 5000 || s.NextPage != 2 {
+		t.Errorf("clamped: TotalPages = %d, NextPage = %d; want 5000 and 2", s.TotalPages, s.NextPage)
+	}
+
+	maxTotalResults = 0 // Без ограничения расчет страниц тоже не должен переполняться
+	s = &Search{CurrentPage: 1}
+	s.paginate(clampTotalResults(math.MaxInt), 20)
+	if want := math.MaxInt/20 + 1; s.TotalPages != want {
+		t.Errorf("unclamped: TotalPages = %d, want %d", s.TotalPages, want)
+	}
+}
+
+func TestSearchHandlerEmptyQuery(t *testing.T) {
+	called := false
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		fmt.Fprint(w, articlesJSON(0, 0))
+	})
+
+	for _, target := range []string{"/search?q=", "/search?q=%20%09%20", "/search"} {
+		rec := httptest.NewRecorder()
+		searchHandler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, http.StatusOK)
+		}
+		if !strings.Contains(rec.Body.String(), `class="search-prompt"`) {
+			t.Errorf("%s: body has no search prompt", target)
+		}
+	}
+	if called {
+		t.Error("NewsAPI was called for an empty query")
+	}
+}
+
+func TestSearchPageMetaTags(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	oldSite := publicSiteURL
+	publicSiteURL = "http://news.example"
+	defer func() { publicSiteURL = oldSite }()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://news.example/search?q=climate&page=2", nil)
+	searchHandler(rec, req)
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		`<title>News about climate</title>`,
+		`<meta property="og:title" content="News about climate">`,
+		`<meta property="og:description" content="About 45 news articles matching climate.">`,
+		`<meta property="og:url" content="http://news.example/search?page=2&amp;q=climate">`,
+		`<meta name="twitter:card" content="summary">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %s", want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	indexHandler(rec, httptest.NewRequest(http.MethodGet, "http://news.example/", nil))
+	if !strings.Contains(rec.Body.String(), `<meta property="og:url" content="http://news.example/">`) {
+		t.Error("home page has no site-wide og:url")
+	}
+}
+
+func TestArticleImageURL(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"", imagePlaceholder},
+		{"   ", imagePlaceholder},
+		{"javascript:alert(1)", imagePlaceholder},
+		{"data:image/png;base64,AAAA", imagePlaceholder},
+		{"/relative/image.jpg", imagePlaceholder},
+		{"https://", imagePlaceholder},
+		{"http://cdn.example/a.jpg", "http://cdn.example/a.jpg"},
+		{" https://cdn.example/b.png?w=400 ", "https://cdn.example/b.png?w=400"},
+	}
+	for _, tt := range tests {
+		a := Article{URLToImage: tt.image}
+		if got := a.ImageURL(); got != tt.want {
+			t.Errorf("ImageURL(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+
+	old := insecureImages
+	insecureImages = "hide"
+	t.Cleanup(func() { insecureImages = old })
+	a := Article{URLToImage: "http://cdn.example/a.jpg"}
+	if got := a.DisplayImageURL(true); got != imagePlaceholder {
+		t.Errorf("DisplayImageURL with hide = %q, want the placeholder", got)
+	}
+}
+
+func TestRunSearchMaxAgeDays(t *testing.T) {
+	recent := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	stale := time.Now().AddDate(0, 0, -30).UTC().Format(time.RFC3339)
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status":"ok","totalResults":3,"articles":[
+			{"title":"fresh","url":"https://example.com/1","publishedAt":%q},
+			{"title":"republished","url":"https://example.com/2","publishedAt":%q},
+			{"title":"undated","url":"https://example.com/3","publishedAt":"not a date"}]}`, recent, stale)
+	})
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/search?q=news", []string{"fresh", "republished", "undated"}},
+		{"/search?q=news&maxAgeDays=0", []string{"fresh", "republished", "undated"}},
+		{"/search?q=news&maxAgeDays=7", []string{"fresh", "undated"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		search, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, tt.target, nil), false)
+		if !ok {
+			t.Fatalf("%s: runSearch failed: %d %s", tt.target, rec.Code, rec.Body)
+		}
+		if got := titles(search.Results.Articles); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: articles = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	search, _ := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=news&maxAgeDays=7", nil), false)
+	if got := search.query(2).Get("maxAgeDays"); got != "7" {
+		t.Errorf("pagination query maxAgeDays = %q, want 7", got)
+	}
+
+	rec = httptest.NewRecorder()
+	if _, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=news&maxAgeDays=-1", nil), false); ok || rec.Code != http.StatusBadRequest {
+		t.Errorf("negative maxAgeDays: ok = %t, status = %d, want a 400", ok, rec.Code)
+	}
+}
+
+func TestCanonicalSearchRedirect(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	old := canonicalRedirect
+	canonicalRedirect = true
+	t.Cleanup(func() { canonicalRedirect = old })
+
+	tests := []struct {
+		target string
+		want   string // Пустой — адрес уже канонический
+	}{
+		{"/search?q=golang", ""},
+		{"/search?sortBy=popularity&q=golang", "/search?q=golang&sortBy=popularity"},
+		{"/search?q=golang&page=1", "/search?q=golang"},
+		{"/search?q=%20golang%20&lang=DE&sortBy=PUBLISHEDAT", "/search?lang=de&q=golang"},
+		{"/search?q=golang&utm_source=mail&pageSize=20", "/search?q=golang"},
+		{"/search?q=golang&trusted=1&domains=BBC.co.uk", "/search?domains=bbc.co.uk&q=golang&trusted=1"},
+		{"/headlines?category=Sports&country=us", "/headlines?category=sports"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if strings.HasPrefix(tt.target, "/headlines") {
+			headlinesHandler(rec, req)
+		} else {
+			searchHandler(rec, req)
+		}
+
+		if tt.want == "" {
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, http.StatusOK)
+			}
+			continue
+		}
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, http.StatusMovedPermanently)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s: Location = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestSearchHandlerContentNegotiation(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+
+	tests := []struct {
+		accept string
+		target string
+		status int
+		json   bool
+	}{
+		{"application/json", "/search?q=golang", http.StatusOK, true},
+		{"application/json, text/plain, */*", "/search?q=golang", http.StatusOK, true},
+		{"text/html;q=0.5, application/json", "/search?q=golang", http.StatusOK, true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "/search?q=golang", http.StatusOK, false},
+		{"*/*", "/search?q=golang", http.StatusOK, false},
+		{"", "/search?q=golang", http.StatusOK, false},
+		{"application/json;q=0.5, text/html", "/search?q=golang", http.StatusOK, false},
+		{"application/json", "/search?q=golang&page=abc", http.StatusBadRequest, true},
+		{"application/json", "/search?q=", http.StatusBadRequest, true},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		searchHandler(rec, req)
+
+		if rec.Code != tt.status {
+			t.Errorf("%q %s: status = %d, want %d", tt.accept, tt.target, rec.Code, tt.status)
+		}
+		contentType := rec.Header().Get("Content-Type")
+		if got := strings.HasPrefix(contentType, "application/json"); got != tt.json {
+			t.Errorf("%q %s: Content-Type = %q, want JSON %t", tt.accept, tt.target, contentType, tt.json)
+		}
+		if !strings.Contains(rec.Header().Get("Vary"), "Accept") {
+			t.Errorf("%q %s: Vary = %q, want Accept", tt.accept, tt.target, rec.Header().Get("Vary"))
+		}
+
+		if !tt.json {
+			continue
+		}
+		var body struct {
+			SearchKey string  `json:"searchKey"`
+			Results   Results `json:"results"`
+			Error     string  `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%q %s: invalid JSON: %v\n%s", tt.accept, tt.target, err, rec.Body)
+			continue
+		}
+		if tt.status == http.StatusOK && (body.SearchKey != "golang" || len(body.Results.Articles) != 20) {
+			t.Errorf("%q %s: searchKey = %q with %d articles, want golang with 20", tt.accept, tt.target, body.SearchKey, len(body.Results.Articles))
+		}
+		if tt.status != http.StatusOK && body.Error == "" {
+			t.Errorf("%q %s: error body has no message: %s", tt.accept, tt.target, rec.Body)
+		}
+	}
+}
+
+func TestRunSearchSearchIn(t *testing.T) {
+	var gotSearchIn []string
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		gotSearchIn = append(gotSearchIn, r.URL.Query().Get("searchIn"))
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+
+	rec := httptest.NewRecorder()
+	search, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=news&searchIn=Content,title,title", nil), false)
+	if !ok {
+		t.Fatalf("runSearch failed: %d %s", rec.Code, rec.Body)
+	}
+	if search.SearchIn != "title,content" {
+		t.Errorf("SearchIn = %q, want title,content", search.SearchIn)
+	}
+	if got := search.query(2).Get("searchIn"); got != "title,content" {
+		t.Errorf("pagination query searchIn = %q, want title,content", got)
+	}
+
+	rec = httptest.NewRecorder()
+	if _, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=news", nil), false); !ok {
+		t.Fatalf("runSearch without searchIn failed: %d %s", rec.Code, rec.Body)
+	}
+	if want := []string{"title,content", ""}; !reflect.DeepEqual(gotSearchIn, want) {
+		t.Errorf("NewsAPI searchIn = %q, want %q", gotSearchIn, want)
+	}
+
+	rec = httptest.NewRecorder()
+	if _, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=news&searchIn=title,body", nil), false); ok || rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid searchIn: ok = %t, status = %d, want a 400", ok, rec.Code)
+	}
+}
+
+func TestComputePagination(t *testing.T) {
+	tests := []struct {
+		name                           string
+		total, pageSize, page, hardCap int
+		want                           pagination
+	}{
+		{"no results", 0, 20, 1, 100, pagination{TotalPages: 1}},
+		{"exactly one page", 20, 20, 1, 100, pagination{TotalPages: 1}},
+		{"one over a page", 21, 20, 1, 100, pagination{TotalPages: 2, NextPage: 2}},
+		{"exact multiple, last page", 40, 20, 2, 100, pagination{TotalPages: 2, PreviousPage: 1}},
+		{"exactly the cap", 100, 20, 5, 100, pagination{TotalPages: 5, PreviousPage: 4}},
+		{"one over the cap", 101, 20, 5, 100, pagination{TotalPages: 5, PreviousPage: 4, Limited: true}},
+		{"cap not a multiple of page size", 500, 30, 3, 100, pagination{TotalPages: 3, PreviousPage: 2, Limited: true}},
+		{"cap below page size", 1000, 50, 1, 30, pagination{TotalPages: 1, Limited: true}},
+		{"no cap", 500, 20, 1, 0, pagination{TotalPages: 25, NextPage: 2}},
+		{"page beyond the end", 40, 20, 7, 0, pagination{TotalPages: 2, PreviousPage: 2}},
+	}
+	for _, tt := range tests {
+		if got := computePagination(tt.total, tt.pageSize, tt.page, tt.hardCap); got != tt.want {
+			t.Errorf("%s: computePagination(%d, %d, %d, %d) = %+v, want %+v", tt.name, tt.total, tt.pageSize, tt.page, tt.hardCap, got, tt.want)
+		}
+	}
+}
+
+func TestResultsDedupe(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 5, 1, hour, 0, 0, 0, time.UTC) }
+	results := Results{TotalResults: 250, Articles: []Article{
+		{Title: "Story", URL: "https://a.example/1", PublishedAt: at(10)},
+		{Title: " story ", URL: "https://b.example/1", PublishedAt: at(8)},
+		{Title: "Other", URL: "https://a.example/1", PublishedAt: at(9)},
+		{Title: "Unique", URL: "https://c.example/1", PublishedAt: at(7)},
+	}}
+
+	if removed := results.dedupe(); removed != 2 {
+		t.Errorf("dedupe removed %d articles, want 2", removed)
+	}
+	var urls []string
+	for _, a := range results.Articles {
+		urls = append(urls, a.URL)
+	}
+	// Из повторов остается самая ранняя публикация
+	if got := strings.Join(urls, ","); got != "https://b.example/1,https://c.example/1" {
+		t.Errorf("dedupe kept %s, want b and c", got)
+	}
+	if results.TotalResults != 250 {
+		t.Errorf("TotalResults = %d, want the upstream 250: duplicates are only known for this page", results.TotalResults)
+	}
+}
+
+func TestExportLinks(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/everything" && r.URL.Query().Get("q") == "" {
+			http.Error(w, `{"status":"error","code":"parametersMissing","message":"q is required"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	exportLinks := regexp.MustCompile(`href="(/export[^"]*)"`)
+	page := func(handler http.HandlerFunc, target string) string {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	if links := exportLinks.FindAllStringSubmatch(page(headlinesHandler, "/headlines?category=science"), -1); len(links) != 0 {
+		t.Errorf("headlines page links to exports %v, which search /everything without a query", links)
+	}
+
+	links := exportLinks.FindAllStringSubmatch(page(searchHandler, "/search?q=climate"), -1)
+	if len(links) != 2 {
+		t.Fatalf("search page export links = %v, want JSON and CSV", links)
+	}
+	for _, link := range links {
+		target := html.UnescapeString(link[1])
+		handler := csvExportHandler
+		if strings.HasPrefix(target, "/export.json") {
+			handler = exportHandler
+		}
+		page(handler, target)
+	}
+}
+
+func TestRunSearchLanguage(t *testing.T) {
+	var upstream []string
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		upstream = append(upstream, r.URL.Query().Get("language"))
+		fmt.Fprint(w, articlesJSON(1, 1))
+	})
+
+	tests := []struct {
+		target, acceptLanguage string
+		status                 int
+		want                   string // Параметр language запроса к NewsAPI; пустой — запроса нет
+	}{
+		{"/search?q=go&lang=de", "", http.StatusOK, "de"},
+		{"/search?q=go&lang=DE", "", http.StatusOK, "de"},
+		{"/search?q=go", "", http.StatusOK, defaultLanguage},
+		{"/search?q=go", "ru-RU,ru;q=0.9", http.StatusOK, defaultLanguage}, // Локаль интерфейса не фильтрует статьи
+		{"/search?q=go&ui=ru", "", http.StatusOK, defaultLanguage},
+		{"/search?q=go&lang=xx", "", http.StatusBadRequest, ""},
+		{"/search?q=go&lang=english", "ru", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		upstream = nil
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+		}
+		rec := httptest.NewRecorder()
+		runSearch(rec, r, false)
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.target, rec.Code, tt.status)
+		}
+		var want []string
+		if tt.want != "" {
+			want = []string{tt.want}
+		}
+		if !reflect.DeepEqual(upstream, want) {
+			t.Errorf("%s (Accept-Language %q): NewsAPI language = %q, want %q", tt.target, tt.acceptLanguage, upstream, want)
+		}
+	}
+}
+
+func TestSearchFormKeepsFilters(t *testing.T) {
+	useIndexTemplate(t)
+	form := func(s *Search) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, s); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		body := buf.String()
+		start := strings.Index(body, `<form action="/search"`)
+		if start < 0 {
+			t.Fatalf("no search form:\n%s", body)
+		}
+		end := strings.Index(body[start:], "</form>")
+		return body[start : start+end]
+	}
+
+	filters := []string{
+		`<input type="hidden" name="trusted" value="1">`,
+		`<input type="hidden" name="pinPopular" value="1">`,
+	}
+	got := form(&Search{SearchKey: "go", Locale: defaultLocale, SortBy: defaultSortBy, TrustedOnly: true, PinPopular: true})
+	for _, want := range filters {
+		if !strings.Contains(got, want) {
+			t.Errorf("search form does not keep %s:\n%s", want, got)
+		}
+	}
+	got = form(&Search{SearchKey: "go", Locale: defaultLocale, SortBy: defaultSortBy})
+	for _, unwanted := range filters {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("search form adds %s without the filter", unwanted)
+		}
+	}
+}
+
+func TestPageWindow(t *testing.T) {
+	tests := []struct {
+		name                   string
+		current, total, radius int
+		want                   []int
+	}{
+		{"first page", 1, 10, 2, []int{1, 2, 3}},
+		{"last page", 10, 10, 2, []int{8, 9, 10}},
+		{"middle", 5, 10, 2, []int{3, 4, 5, 6, 7}},
+		{"fewer pages than the window", 2, 3, 3, []int{1, 2, 3}},
+		{"single page", 1, 1, 3, nil},
+		{"page past the end", 11, 10, 2, nil},
+		{"page zero", 0, 10, 2, nil},
+		{"zero radius", 4, 10, 0, []int{4}},
+	}
+	for _, tt := range tests {
+		if got := pageWindow(tt.current, tt.total, tt.radius); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: pageWindow(%d, %d, %d) = %v, want %v", tt.name, tt.current, tt.total, tt.radius, got, tt.want)
+		}
+	}
+}
+
+func TestPaginateWindowEdges(t *testing.T) {
+	oldRadius, oldLimit, oldLinks := pageWindowRadius, resultLimit, firstLastLinks
+	defer func() { pageWindowRadius, resultLimit, firstLastLinks = oldRadius, oldLimit, oldLinks }()
+	pageWindowRadius, firstLastLinks = 2, true
+
+	tests := []struct {
+		name                       string
+		total, current, limit      int
+		window                     []int
+		first, last, leading, tail bool // ShowFirstLink, ShowLastLink, HasLeadingGap, HasTrailingGap
+	}{
+		{"first page", 200, 1, 0, []int{1, 2, 3}, false, true, false, true},
+		{"last page", 200, 10, 0, []int{8, 9, 10}, true, false, true, false},
+		{"next to the first page", 200, 4, 0, []int{2, 3, 4, 5, 6}, true, true, false, true},
+		{"fewer pages than the window", 50, 2, 0, []int{1, 2, 3}, false, false, false, false},
+		{"single page", 15, 1, 0, nil, false, false, false, false},
+		{"results limit caps the window", 1000, 5, 100, []int{3, 4, 5}, true, false, true, false},
+		{"results limit on an exact multiple", 1000, 4, 80, []int{2, 3, 4}, true, false, false, false},
+	}
+	for _, tt := range tests {
+		resultLimit = tt.limit
+		s := &Search{CurrentPage: tt.current}
+		s.paginate(tt.total, 20)
+
+		if !reflect.DeepEqual(s.PageWindow, tt.window) {
+			t.Errorf("%s: PageWindow = %v, want %v", tt.name, s.PageWindow, tt.window)
+		}
+		got := []bool{s.ShowFirstLink(), s.ShowLastLink(), s.HasLeadingGap(), s.HasTrailingGap()}
+		if want := []bool{tt.first, tt.last, tt.leading, tt.tail}; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: first/last links and gaps = %v, want %v", tt.name, got, want)
+		}
+		if n := len(s.PageWindow); n > 0 && s.PageWindow[n-1] > s.TotalPages {
+			t.Errorf("%s: window %v goes past TotalPages %d", tt.name, s.PageWindow, s.TotalPages)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var maintenance atomic.Bool // Сайт закрыт на обслуживание; задается -maintenance и /admin/maintenance
+
+var maintenanceRetryAfter = 5 * time.Minute // Через сколько посетителям предлагают зайти снова
+
+// maintenanceExempt — адреса, которые работают и во время обслуживания:
+// проверки здоровья, админка (иначе режим не выключить) и статика для
+// оформления самой страницы.
+var maintenanceExempt = []string{"/healthz", "/readyz", "/metrics", "/favicon.ico", "/admin/", "/assets/"}
+
+func isMaintenanceExempt(path string) bool {
+	for _, p := range maintenanceExempt {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveMaintenance отвечает 503 со страницей maintenance.html на все запросы,
+// кроме maintenanceExempt, пока включен режим обслуживания.
+func serveMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !maintenance.Load() || isMaintenanceExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(int(maintenanceRetryAfter.Seconds())))
+		search := &Search{
+			ReaderMode: readerMode(r),
+			Theme:      requestTheme(r),
+			Locale:     requestLocale(r),
+		}
+		var buf bytes.Buffer
+		if err := templates().Maintenance.Execute(&buf, search); err != nil {
+			log.Printf("Error executing maintenance template: %v", err)
+			http.Error(w, "The site is down for maintenance, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(buf.Bytes())
+	})
+}
+
+// MaintenanceStatus — тело запроса и ответа /admin/maintenance.
+type MaintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// adminMaintenanceHandler показывает (GET) и переключает (POST с JSON
+// {"enabled": true}) режим обслуживания без перезапуска.
+func adminMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var status MaintenanceStatus
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			http.Error(w, `JSON body {"enabled": true|false} is required`, http.StatusBadRequest)
+			return
+		}
+		if maintenance.Swap(status.Enabled) != status.Enabled {
+			log.Printf("Maintenance mode enabled: %t", status.Enabled)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MaintenanceStatus{Enabled: maintenance.Load()})
+}
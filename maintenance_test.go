@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeMaintenance(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	oldToken := adminToken
+	adminToken = "secret"
+	t.Cleanup(func() {
+		adminToken = oldToken
+		maintenance.Store(false)
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/admin/maintenance", adminMaintenanceHandler)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("page")) })
+	handler := serveMaintenance(mux)
+
+	toggle := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := get("/search?q=go"); rec.Code != http.StatusOK {
+		t.Fatalf("before maintenance: status %d, want 200", rec.Code)
+	}
+
+	if rec := toggle(`{"enabled": true}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":true`) {
+		t.Fatalf("enable: %d %s", rec.Code, rec.Body)
+	}
+	rec := get("/search?q=go")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("during maintenance: status %d, Retry-After %q; want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), "Down for maintenance") {
+		t.Errorf("maintenance page not rendered:\n%s", rec.Body)
+	}
+	if rec := get("/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz during maintenance: status %d, want 200", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/maintenance", strings.NewReader(`{"enabled": false}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || !maintenance.Load() {
+		t.Errorf("toggle without token: status %d, maintenance %t; want 401 and still enabled", rec.Code, maintenance.Load())
+	}
+
+	toggle(`{"enabled": false}`)
+	if rec := get("/search?q=go"); rec.Code != http.StatusOK {
+		t.Errorf("after maintenance: status %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const siteName = "News Site"
+
+var canonicalRedirect bool // Перенаправлять ли /search и /headlines с неканоническими параметрами на CanonicalURL
+
+// setMeta заполняет заголовок, описание и канонический адрес страницы для
+// <title>, OpenGraph и Twitter Card. Вызывается, когда результаты уже есть.
+func (s *Search) setMeta(r *http.Request) {
+	site := siteURL(r)
+	switch {
+	case s.Headlines:
+		s.PageTitle = translateText(s.Locale, "meta.headlines.title")
+		if s.Category != "" {
+			s.PageTitle = translateText(s.Locale, "meta.category.title", translateText(s.Locale, "category."+s.Category))
+		}
+		s.Description = translateText(s.Locale, "meta.headlines.description", s.Results.TotalResults)
+	case s.SearchKey != "":
+		s.PageTitle = translateText(s.Locale, "meta.search.title", s.SearchKey)
+		s.Description = translateText(s.Locale, "meta.search.description", s.Results.TotalResults, s.SearchKey)
+	default:
+		s.PageTitle = siteName
+		s.Description = translateText(s.Locale, "meta.home")
+		s.CanonicalURL = site + "/"
+		return
+	}
+
+	s.CanonicalURL = site + canonicalPath(r.URL.Path, s.canonicalQuery())
+}
+
+// canonicalQuery — параметры поиска в единой форме: только те, что влияют на
+// выдачу, без значений по умолчанию (пустой q, первая страница) и уже
+// приведенные runSearch к нижнему регистру. Encode сортирует ключи, так что
+// одинаковые поиски дают одинаковые адреса.
+func (s *Search) canonicalQuery() url.Values {
+	v := s.query(s.CurrentPage)
+	if v.Get("q") == "" {
+		v.Del("q")
+	}
+	if s.CurrentPage <= 1 {
+		v.Del("page")
+	}
+	return v
+}
+
+func canonicalPath(path string, v url.Values) string {
+	if len(v) == 0 {
+		return path
+	}
+	return path + "?" + v.Encode()
+}
+
+// redirectToCanonical отправляет GET /search и /headlines с параметрами в
+// неканонической форме на канонический адрес (301), если включен
+// -canonicalredirect. Возвращает true, если ответ уже отправлен.
+func redirectToCanonical(w http.ResponseWriter, r *http.Request, s *Search) bool {
+	if !canonicalRedirect || r.Method != http.MethodGet || (r.URL.Path != "/search" && r.URL.Path != "/headlines") {
+		return false
+	}
+	v := s.canonicalQuery()
+	if v.Encode() == r.URL.RawQuery {
+		return false
+	}
+	http.Redirect(w, r, canonicalPath(r.URL.Path, v), http.StatusMovedPermanently)
+	return true
+}
+
+// translateText — translate для обычного текста: аргументы не экранируются,
+// это сделает шаблон при выводе.
+func translateText(locale, key string, args ...interface{}) string {
+	msg, ok := messages[locale][key]
+	if !ok {
+		msg, ok = messages[defaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(msg, args...)
+}
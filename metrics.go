@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Метрики в текстовом формате Prometheus. Метки — только из небольших
+// фиксированных наборов (шаблон маршрута, код ответа, вид кэша): сырой
+// запрос пользователя в метки не попадает. Счетчики и гистограмма свои, без
+// prometheus/client_golang; почему — см. раздел Metrics в README.
+var (
+	httpRequests = newCounterVec("news_http_requests_total",
+		"HTTP requests served, by route pattern and status code.", "handler", "code")
+	newsAPILatency = newHistogram("news_newsapi_request_duration_seconds",
+		"Latency of individual NewsAPI HTTP requests, retries included.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10})
+	cacheLookups = newCounterVec("news_cache_lookups_total",
+		"Cache lookups, by cache and result.", "cache", "result")
+	upstreamErrors = newCounterVec("news_newsapi_errors_total",
+		"Failed NewsAPI calls, by kind of failure.", "kind")
+)
+
+// metric — то, что умеет вывести себя в формате Prometheus.
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var registeredMetrics = []metric{httpRequests, newsAPILatency, cacheLookups, upstreamErrors}
+
+// counterVec — счетчик с набором меток.
+type counterVec struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	values map[string]float64 // Ключ — значения меток через \xff
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Inc увеличивает счетчик для значений меток в порядке их объявления.
+func (c *counterVec) Inc(labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[strings.Join(labelValues, "\xff")]++
+}
+
+// Sum складывает счетчики, у которых первые метки равны labelValues.
+// Без аргументов — сумма по всем меткам.
+func (c *counterVec) Sum(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := strings.Join(labelValues, "\xff")
+	var total float64
+	for key, v := range c.values {
+		if len(labelValues) == 0 || key == prefix || strings.HasPrefix(key, prefix+"\xff") {
+			total += v
+		}
+	}
+	return total
+}
+
+func (c *counterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, strings.Split(key, "\xff")), formatValue(c.values[key]))
+	}
+}
+
+// histogram — гистограмма без меток с фиксированными границами корзин.
+type histogram struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	buckets []float64
+	counts  []uint64 // counts[i] — наблюдения <= buckets[i], без накопления
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(name, help string, buckets []float64) *histogram {
+	return &histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// Observe записывает одно наблюдение.
+func (h *histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// Totals возвращает число наблюдений и их сумму.
+func (h *histogram) Totals() (uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count, h.sum
+}
+
+func (h *histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	var cumulative uint64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatValue(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatValue(h.sum), h.name, h.count)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + "=" + strconv.Quote(value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricsHandler отдает все метрики в текстовом формате Prometheus.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	for _, m := range registeredMetrics {
+		m.write(bw)
+	}
+	bw.Flush()
+}
+
+// countRequests считает ответы по шаблону маршрута mux и коду ответа.
+// Шаблон, а не путь, держит число меток небольшим.
+func countRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.Inc(pattern, strconv.Itoa(status))
+	})
+}
+
+// observeNewsAPI записывает время одного запроса к NewsAPI.
+func observeNewsAPI(start time.Time) {
+	newsAPILatency.Observe(time.Since(start).Seconds())
+}
+
+// countUpstreamError относит ошибку обращения к NewsAPI к одному из
+// фиксированных видов. Ушедший клиент ошибкой NewsAPI не считается.
+func countUpstreamError(err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return
+	case errors.Is(err, ErrUpstreamTimeout):
+		upstreamErrors.Inc("timeout")
+	case errors.Is(err, ErrAllKeysExhausted):
+		upstreamErrors.Inc("rate_limited")
+	case errors.Is(err, ErrQuotaExhausted):
+		upstreamErrors.Inc("quota")
+	default:
+		upstreamErrors.Inc("network")
+	}
+}
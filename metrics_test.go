@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterVecSum(t *testing.T) {
+	c := newCounterVec("test_total", "Test.", "cache", "result")
+	c.Inc("results", "hit")
+	c.Inc("results", "hit")
+	c.Inc("results", "miss")
+	c.Inc("page", "hit")
+
+	tests := []struct {
+		labels []string
+		want   float64
+	}{
+		{nil, 4},
+		{[]string{"results"}, 3},
+		{[]string{"results", "hit"}, 2},
+		{[]string{"page", "miss"}, 0},
+		{[]string{"res"}, 0}, // Префикс значения метки — не совпадение
+	}
+	for _, tt := range tests {
+		if got := c.Sum(tt.labels...); got != tt.want {
+			t.Errorf("Sum(%q) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentStats(t *testing.T) {
+	oldRequests, oldCache, oldLatency, oldStart := httpRequests, cacheLookups, newsAPILatency, startTime
+	t.Cleanup(func() {
+		httpRequests, cacheLookups, newsAPILatency, startTime = oldRequests, oldCache, oldLatency, oldStart
+	})
+	httpRequests = newCounterVec("r", "", "handler", "code")
+	cacheLookups = newCounterVec("c", "", "cache", "result")
+	newsAPILatency = newHistogram("l", "", []float64{1})
+
+	now := time.Now()
+	startTime = now.Add(-90 * time.Second)
+	httpRequests.Inc("/search", "200")
+	httpRequests.Inc("/", "200")
+	cacheLookups.Inc("results", "hit")
+	cacheLookups.Inc("results", "miss")
+	cacheLookups.Inc("results", "miss")
+	cacheLookups.Inc("results", "miss")
+	cacheLookups.Inc("page", "hit") // Кэш страниц в долю не входит
+	newsAPILatency.Observe(0.1)
+	newsAPILatency.Observe(0.3)
+
+	got := currentStats(now)
+	want := StatsSummary{UptimeSeconds: 90, Requests: 2, NewsAPICalls: 2, CacheHitRatio: 0.25, AvgUpstreamMillis: 200}
+	if got.UptimeSeconds != want.UptimeSeconds || got.Requests != want.Requests || got.NewsAPICalls != want.NewsAPICalls ||
+		got.CacheHitRatio != want.CacheHitRatio || int(got.AvgUpstreamMillis+0.5) != int(want.AvgUpstreamMillis) {
+		t.Errorf("currentStats = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// statusRecorder запоминает код ответа для журнала запросов.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logRequests пишет в журнал каждый запрос с кодом ответа и временем обработки.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		slog.InfoContext(r.Context(), "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status_code", status,
+			"latency_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// internalErrorPage — страница 500 после паники. Собрана без шаблонов: паника
+// могла случиться как раз в них.
+const internalErrorPage = `<!DOCTYPE html>
+<html lang="%s">
+<head>
+    <title>%s - News Site</title>
+    <link rel="stylesheet" href="/assets/style.css">
+</head>
+<body>
+    <main>
+        <section class="container message-page">
+            <h1>%s</h1>
+            <p>%s</p>
+            <a href="/" class="button">%s</a>
+        </section>
+    </main>
+</body>
+</html>
+`
+
+// recoverPanics перехватывает панику в обработчике: пишет в журнал стек с ID
+// запроса и отвечает страницей 500 вместо оборванного соединения. Если ответ
+// уже начат, заменить его нечем, и соединение закрывается.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			slog.ErrorContext(r.Context(), "Panic in handler",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"error", fmt.Sprint(err),
+				"stack", string(debug.Stack()),
+			)
+			if rec.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+
+			locale := requestLocale(r)
+			h := w.Header()
+			for _, name := range []string{"Cache-Control", "Content-Encoding", "Content-Length", "ETag", "Last-Modified"} {
+				h.Del(name) // Могли остаться от обработчика
+			}
+			h.Set("Content-Type", "text/html; charset=utf-8")
+			h.Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, internalErrorPage, locale,
+				translate(locale, "error.title"), translate(locale, "error.title"),
+				translate(locale, "error.text"), translate(locale, "notfound.home"))
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+var (
+	assetMaxAge = 24 * time.Hour   // Сколько браузер хранит статику из /assets/ без проверки
+	pageMaxAge  = 60 * time.Second // Cache-Control страниц поиска; 0 — всегда проверять
+)
+
+const immutableMaxAge = 365 * 24 * time.Hour
+
+// fingerprintPattern узнает файлы с хэшем содержимого в имени
+// (style.3f2a9c1b.css): при изменении файла меняется имя, так что хранить
+// их можно сколько угодно.
+var fingerprintPattern = regexp.MustCompile(`\.[0-9a-f]{8,}\.[a-z0-9]+$`)
+
+// cacheAssets добавляет Cache-Control к ответам FileServer. Ошибки (404 на
+// еще не выложенный файл) не кэшируются.
+func cacheAssets(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fingerprintPattern.MatchString(path.Base(r.URL.Path)) {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(immutableMaxAge.Seconds())))
+		} else {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(assetMaxAge.Seconds())))
+		}
+		next.ServeHTTP(&uncachedErrors{w}, r)
+	})
+}
+
+type uncachedErrors struct {
+	http.ResponseWriter
+}
+
+func (u *uncachedErrors) WriteHeader(status int) {
+	if status >= 400 {
+		u.Header().Del("Cache-Control")
+	}
+	u.ResponseWriter.WriteHeader(status)
+}
+
+func (u *uncachedErrors) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+const gzipMinSize = 1024 // Ответы меньше этого размера не сжимаем
+
+// gzipResponses сжимает ответы, если клиент принимает gzip. Статику из
+// /assets/ отдает FileServer как есть.
+func gzipResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/assets/") || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		w.Header().Add("Vary", "Accept-Encoding")
+		next.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(enc, "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter копит начало ответа, пока не станет ясно, стоит ли его
+// сжимать: тело должно быть не меньше gzipMinSize и иметь текстовый тип.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipResponseWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(b)
+		}
+		return g.ResponseWriter.Write(b)
+	}
+
+	g.buf = append(g.buf, b...)
+	if len(g.buf) >= gzipMinSize {
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide выбирает между сжатием и передачей как есть и отправляет накопленное.
+func (g *gzipResponseWriter) decide(bigEnough bool) error {
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Type") == "" && len(g.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	if bigEnough && h.Get("Content-Encoding") == "" && isCompressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.ResponseWriter.WriteHeader(g.status)
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+		_, err := g.gz.Write(g.buf)
+		g.buf = nil
+		return err
+	}
+
+	g.ResponseWriter.WriteHeader(g.status)
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+// Flush отправляет клиенту все, что уже записано, сжимая, если возможно.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(len(g.buf) > 0)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Close дописывает ответ; вызывается после обработчика.
+func (g *gzipResponseWriter) Close() {
+	if !g.decided {
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
+
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// isCompressible сообщает, имеет ли смысл сжимать ответ такого типа.
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "application/javascript",
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+	return false
+}
+
+var corsOrigins = []string{"*"} // Origin, которым разрешено звать JSON API из браузера; пустой список отключает CORS
+
+// allowCORS добавляет CORS-заголовки к ответам JSON API и отвечает 204 на
+// preflight-запросы OPTIONS. HTML-страницы им не оборачиваются.
+func allowCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := allowedOrigin(r.Header.Get("Origin"))
+		if len(corsOrigins) > 0 && origin != "*" {
+			// Ответ зависит от Origin и для чужих адресов: без Vary кэш отдал бы
+			// ответ без заголовков разрешенному origin
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin != "" {
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Content-Type")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedOrigin возвращает значение Access-Control-Allow-Origin для origin
+// или пустую строку, если этому origin доступ не разрешен.
+func allowedOrigin(origin string) string {
+	for _, allowed := range corsOrigins {
+		switch {
+		case allowed == "*":
+			return "*"
+		case origin != "" && strings.EqualFold(allowed, origin):
+			return origin
+		}
+	}
+	return ""
+}
+
+// parseOrigins разбирает список origin через запятую.
+func parseOrigins(list string) []string {
+	var origins []string
+	for _, origin := range strings.Split(list, ",") {
+		if origin = strings.TrimRight(strings.TrimSpace(origin), "/"); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheAssets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"style.css", "app.5d41402abc4b2a76.js"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := http.StripPrefix("/assets/", cacheAssets(http.FileServer(http.Dir(dir))))
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/assets/style.css", http.StatusOK, "public, max-age=86400"},
+		{"/assets/app.5d41402abc4b2a76.js", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"/assets/missing.css", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.path, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPageCacheControl(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(articlesJSON(1, 1)))
+	})
+	old := pageMaxAge
+	t.Cleanup(func() { pageMaxAge = old })
+
+	for maxAge, want := range map[int]string{60: "private, max-age=60", 0: "private, no-cache"} {
+		pageMaxAge = time.Duration(maxAge) * time.Second
+		rec := httptest.NewRecorder()
+		searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=go", nil))
+		if got := rec.Header().Get("Cache-Control"); got != want {
+			t.Errorf("pageMaxAge %ds: Cache-Control = %q, want %q", maxAge, got, want)
+		}
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		var m map[string]int
+		m["x"] = 1 // Запись в nil map
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fine"))
+	})
+	handler := withRequestID(recoverPanics(mux))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/boom?ui=ru", nil)
+	req.Header.Set(requestIDHeader, "panic-test")
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	if got := rec.Header().Get(requestIDHeader); got != "panic-test" {
+		t.Errorf("X-Request-ID = %q, want panic-test", got)
+	}
+	if !strings.Contains(rec.Body.String(), messages["ru"]["error.title"]) {
+		t.Errorf("body has no localized error page:\n%s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "fine" {
+		t.Errorf("/ok = %d %q, want 200 fine", rec.Code, rec.Body)
+	}
+}
+
+func TestRecoverPanicsAfterResponseStarted(t *testing.T) {
+	handler := recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		panic("late failure")
+	}))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func TestGzipResponses(t *testing.T) {
+	big := strings.Repeat("<p>news</p>", gzipMinSize)
+	tests := []struct {
+		name        string
+		method      string
+		status      int
+		contentType string
+		body        string
+		wantGzip    bool
+	}{
+		{"large HTML", http.MethodGet, http.StatusOK, "text/html; charset=utf-8", big, true},
+		{"large JSON", http.MethodGet, http.StatusOK, "application/json", big, true},
+		{"small body", http.MethodGet, http.StatusOK, "text/html; charset=utf-8", "<p>short</p>", false},
+		{"image", http.MethodGet, http.StatusOK, "image/png", big, false},
+		{"not modified", http.MethodGet, http.StatusNotModified, "", "", false},
+		{"HEAD", http.MethodHead, http.StatusOK, "text/html; charset=utf-8", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(len(tt.body)))
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, "/search?q=go", nil)
+			r.Header.Set("Accept-Encoding", "gzip, deflate")
+			handler.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			body := rec.Body.String()
+			if tt.wantGzip {
+				if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" {
+					t.Fatalf("headers = %v, want gzip without Content-Length", rec.Header())
+				}
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader: %v", err)
+				}
+				plain, err := io.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("reading gzip body: %v", err)
+				}
+				body = string(plain)
+			} else if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want the body as is", got)
+			}
+			if body != tt.body {
+				t.Errorf("body is %d bytes, want the original %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestGzipResponsesPassThrough(t *testing.T) {
+	big := strings.Repeat("a", 2*gzipMinSize)
+	handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(big))
+	}))
+	for _, tt := range []struct{ path, acceptEncoding string }{
+		{"/search", ""},
+		{"/search", "gzip;q=0"},
+		{"/assets/style.css", "gzip"},
+	} {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		handler.ServeHTTP(rec, r)
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != big {
+			t.Errorf("%s with Accept-Encoding %q was compressed", tt.path, tt.acceptEncoding)
+		}
+	}
+}
+
+func TestGzipResponsesFlush(t *testing.T) {
+	handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("first chunk"))
+		http.NewResponseController(w).Flush()
+		w.Write([]byte(", second chunk"))
+	}))
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/feed?q=go", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, r)
+
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("flushed = %v, headers = %v; want a flushed gzip stream", rec.Flushed, rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	if plain, _ := io.ReadAll(zr); string(plain) != "first chunk, second chunk" {
+		t.Errorf("body = %q, want both chunks", plain)
+	}
+}
+
+func TestAllowCORS(t *testing.T) {
+	oldOrigins := corsOrigins
+	defer func() { corsOrigins = oldOrigins }()
+
+	var served int
+	handler := allowCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Write([]byte(`{}`))
+	}))
+	request := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/search?q=go", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if preflight {
+			r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	corsOrigins = parseOrigins(" https://app.example/ , https://other.example")
+	if want := []string{"https://app.example", "https://other.example"}; !slices.Equal(corsOrigins, want) {
+		t.Fatalf("parseOrigins = %q, want %q", corsOrigins, want)
+	}
+
+	rec := request(http.MethodGet, "https://APP.example", false)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://APP.example" {
+		t.Errorf("allowed origin: Access-Control-Allow-Origin = %q, want the request origin", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("allowed origin: Vary = %q, want Origin", got)
+	}
+
+	rec = request(http.MethodGet, "https://evil.example", false)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("disallowed origin: Access-Control-Allow-Origin = %q, want none", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("disallowed origin: Vary = %q, want Origin so caches keep the variants apart", got)
+	}
+
+	served = 0
+	rec = request(http.MethodOptions, "https://app.example", true)
+	if rec.Code != http.StatusNoContent || served != 0 {
+		t.Errorf("preflight: status = %d, handler calls = %d; want 204 without the handler", rec.Code, served)
+	}
+	if rec.Header().Get("Access-Control-Allow-Methods") != "GET, OPTIONS" || rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight headers = %v", rec.Header())
+	}
+
+	corsOrigins = []string{"*"}
+	rec = request(http.MethodGet, "https://any.example", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Vary") != "" {
+		t.Errorf("wildcard: headers = %v, want * without Vary", rec.Header())
+	}
+
+	corsOrigins = nil
+	rec = request(http.MethodGet, "https://app.example", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("CORS disabled: headers = %v, want none", rec.Header())
+	}
+}
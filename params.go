@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var strictParams bool // Отклонять ли /search с незнакомыми параметрами в адресе
+
+// searchParamNames — параметры адреса, которые понимает /search. Новый
+// параметр поиска нужно добавить и сюда, иначе -strictparams его отклонит.
+var searchParamNames = []string{
+	"author", "category", "country", "domains", "excludeDomains", "from",
+	"lang", "maxAgeDays", "page", "pageSize", "pinPopular", "q", "searchIn",
+	"sortBy", "to", "trusted", "ui",
+}
+
+// unknownParamsError возвращает текст ошибки 400, если в v есть параметры не
+// из searchParamNames, и пустую строку, если незнакомых нет.
+func unknownParamsError(v url.Values) string {
+	var unknown []string
+	for name := range v {
+		if !isSearchParam(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) == 0 {
+		return ""
+	}
+	sort.Strings(unknown)
+	return fmt.Sprintf("Unknown query parameter %s; allowed: %s", strings.Join(unknown, ", "), strings.Join(searchParamNames, ", "))
+}
+
+func isSearchParam(name string) bool {
+	for _, p := range searchParamNames {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearchHandlerStrictParams(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	old := strictParams
+	t.Cleanup(func() { strictParams = old })
+
+	tests := []struct {
+		strict bool
+		target string
+		want   int
+	}{
+		{false, "/search?q=golang&pag=2", http.StatusOK},
+		{true, "/search?q=golang&page=2&sortBy=popularity", http.StatusOK},
+		{true, "/search?q=golang&pag=2", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		strictParams = tt.strict
+		rec := httptest.NewRecorder()
+		searchHandler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("strict=%v %s: status %d, want %d: %s", tt.strict, tt.target, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	strictParams = true
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&utm_source=mail&pag=2", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "pag, utm_source") || !strings.Contains(body, "allowed: author") {
+		t.Errorf("error should list unknown and allowed parameters, got %q", body)
+	}
+}
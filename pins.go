@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// pinStore хранит статьи, закрепленные редакцией над результатами поиска.
+type pinStore struct {
+	mu       sync.RWMutex
+	articles []Article
+}
+
+// loadPins читает закрепленные статьи из JSON-файла с массивом статей.
+// Пустой путь означает пустой список.
+func loadPins(path string) (*pinStore, error) {
+	store := &pinStore{}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read pins file: %w", err)
+	}
+	if err := json.Unmarshal(data, &store.articles); err != nil {
+		return nil, fmt.Errorf("parse pins file %s: %w", path, err)
+	}
+	for i := range store.articles {
+		store.articles[i].Pinned = true
+	}
+	return store, nil
+}
+
+// List возвращает копию списка закрепленных статей.
+func (p *pinStore) List() []Article {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]Article(nil), p.articles...)
+}
+
+// Add закрепляет статью, заменяя уже закрепленную с тем же URL.
+func (p *pinStore) Add(a Article) {
+	a.Pinned = true
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.articles {
+		if p.articles[i].URL == a.URL {
+			p.articles[i] = a
+			return
+		}
+	}
+	p.articles = append(p.articles, a)
+}
+
+// Remove открепляет статью по URL и сообщает, была ли она закреплена.
+func (p *pinStore) Remove(articleURL string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.articles {
+		if p.articles[i].URL == articleURL {
+			p.articles = append(p.articles[:i], p.articles[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Matching возвращает закрепленные статьи, в заголовке или описании которых
+// встречаются все слова запроса (без учета регистра и операторов).
+func (p *pinStore) Matching(query string) []Article {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	var matched []Article
+	for _, a := range p.List() {
+		text := strings.ToLower(a.Title + " " + a.Description)
+		all := true
+		for _, term := range terms {
+			if !strings.Contains(text, term) {
+				all = false
+				break
+			}
+		}
+		if all {
+			matched = append(matched, a)
+		}
+	}
+	return matched
+}
+
+// applyPins убирает из результатов API статьи с URL закрепленных статей и,
+// если prepend, ставит закрепленные статьи перед результатами.
+func applyPins(articles, pinned []Article, prepend bool) []Article {
+	if len(pinned) == 0 {
+		return articles
+	}
+
+	seen := make(map[string]bool, len(pinned))
+	out := make([]Article, 0, len(pinned)+len(articles))
+	for _, a := range pinned {
+		seen[a.URL] = true
+		if prepend {
+			out = append(out, a)
+		}
+	}
+	for _, a := range articles {
+		if !seen[a.URL] {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPinStoreMatching(t *testing.T) {
+	p := &pinStore{}
+	p.Add(Article{Title: "Go 1.22 released", Description: "New loop semantics", URL: "https://example.com/go"})
+	p.Add(Article{Title: "Rust 2024 edition", URL: "https://example.com/rust"})
+	p.Add(Article{Title: "Go 1.22 is out", URL: "https://example.com/go"}) // Тот же URL заменяет статью
+
+	if got := p.List(); len(got) != 2 || got[0].Title != "Go 1.22 is out" || !got[0].Pinned {
+		t.Fatalf("List = %+v, want the replaced Go article first and pinned", got)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"go", 1},
+		{`"GO" AND out`, 1},
+		{"go -rust", 1},
+		{"go rust", 0},
+		{"NOT", 0},
+	}
+	for _, tt := range tests {
+		if got := p.Matching(tt.query); len(got) != tt.want {
+			t.Errorf("Matching(%q) = %d articles, want %d", tt.query, len(got), tt.want)
+		}
+	}
+
+	if !p.Remove("https://example.com/rust") || p.Remove("https://example.com/rust") {
+		t.Error("Remove should succeed once and then report the article as not pinned")
+	}
+}
+
+func TestApplyPins(t *testing.T) {
+	pinned := []Article{{Title: "pinned", URL: "https://example.com/1", Pinned: true}}
+	articles := []Article{
+		{Title: "api copy", URL: "https://example.com/1"},
+		{Title: "other", URL: "https://example.com/2"},
+	}
+
+	got := applyPins(articles, pinned, true)
+	if len(got) != 2 || got[0].Title != "pinned" || got[1].Title != "other" {
+		t.Errorf("applyPins(prepend) = %+v, want pinned then other", got)
+	}
+	got = applyPins(articles, pinned, false)
+	if len(got) != 1 || got[0].Title != "other" {
+		t.Errorf("applyPins(later page) = %+v, want only the API duplicate removed", got)
+	}
+	if got := applyPins(articles, nil, true); len(got) != 2 {
+		t.Errorf("applyPins without pins = %+v, want articles unchanged", got)
+	}
+}
+
+func TestPinnedBadge(t *testing.T) {
+	useIndexTemplate(t)
+	search := &Search{
+		SearchKey:   "go",
+		CurrentPage: 1,
+		TotalPages:  1,
+		Locale:      defaultLocale,
+		Results: Results{TotalResults: 2, Articles: []Article{
+			{Title: "Pinned story", URL: "https://example.com/1", Pinned: true},
+			{Title: "Regular story", URL: "https://example.com/2"},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, search); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if n := strings.Count(buf.String(), `class="pinned-badge"`); n != 1 {
+		t.Errorf("pinned badge rendered %d times, want once", n)
+	}
+}
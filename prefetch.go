@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var prefetchNext bool // Загружать ли в кэш следующую страницу выдачи, пока посетитель читает текущую
+
+var prefetchTimeout = 10 * time.Second
+
+// prefetchSlots ограничивает число фоновых запросов: при всплеске трафика
+// лишние предзагрузки просто пропускаются.
+var prefetchSlots = make(chan struct{}, 4)
+
+var prefetching sync.Map // Ключи resultsCache, которые уже загружаются
+
+// prefetchNextPage в фоне загружает в resultsCache страницу search.NextPage,
+// чтобы переход по "Next" не ждал NewsAPI. Ничего не делает, если следующей
+// страницы нет, кэш выключен или страница уже в нем. Запрос живет дольше
+// ctx запроса посетителя, но ограничен prefetchTimeout.
+func prefetchNextPage(ctx context.Context, search *Search) {
+	if !prefetchNext || search.NextPage == 0 || resultsCache.ttl <= 0 {
+		return
+	}
+
+	page, pageSize := search.NextPage, search.PageSize
+	var key string
+	var fetch func(ctx context.Context) error
+	if search.Headlines {
+		category, country := search.Category, search.Country
+		key = resultsCacheKey("top-headlines", topHeadlinesParams(category, country), pageSize, page)
+		fetch = func(ctx context.Context) error {
+			_, err := getTopHeadlines(ctx, category, country, pageSize, page)
+			return err
+		}
+	} else {
+		q, news := search.newsQuery(), searchNews
+		key = resultsCacheKey("everything", everythingParams(q), pageSize, page)
+		fetch = func(ctx context.Context) error {
+			_, err := news(ctx, q, pageSize, page)
+			return err
+		}
+	}
+
+	if _, ok := resultsCache.Get(key); ok {
+		return
+	}
+	if _, loaded := prefetching.LoadOrStore(key, true); loaded {
+		return
+	}
+	select {
+	case prefetchSlots <- struct{}{}:
+	default:
+		prefetching.Delete(key)
+		slog.InfoContext(ctx, "Skipping prefetch, too many in flight", "page", page)
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx) // Сохраняем request_id для журнала, но не отмену запроса
+	go func() {
+		defer func() {
+			<-prefetchSlots
+			prefetching.Delete(key)
+		}()
+		defer func() {
+			if err := recover(); err != nil {
+				slog.ErrorContext(ctx, "Panic while prefetching", "page", page, "error", fmt.Sprint(err))
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(ctx, prefetchTimeout)
+		defer cancel()
+		if err := fetch(ctx); err != nil {
+			slog.WarnContext(ctx, "Prefetch failed", "page", page, "error", err)
+			return
+		}
+		slog.InfoContext(ctx, "Prefetched next page", "page", page)
+	}()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// enablePrefetch включает предзагрузку и настоящий resultsCache поверх
+// newMockNewsAPI, который кэш отключает.
+func enablePrefetch(t *testing.T) {
+	t.Helper()
+	oldPrefetch, oldCache := prefetchNext, resultsCache
+	prefetchNext = true
+	resultsCache = newTTLCache[Results](time.Minute, 100)
+	t.Cleanup(func() { prefetchNext, resultsCache = oldPrefetch, oldCache })
+}
+
+// waitPrefetch ждет, пока завершатся фоновые загрузки.
+func waitPrefetch(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(prefetchSlots) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("prefetch did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPrefetchNextPage(t *testing.T) {
+	var mu sync.Mutex
+	requested := map[string]int{}
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Query().Get("page")]++
+		mu.Unlock()
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	enablePrefetch(t)
+
+	searchHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+	waitPrefetch(t)
+	searchHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=golang&page=2", nil))
+	waitPrefetch(t)
+	searchHandler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=golang&page=3", nil))
+	waitPrefetch(t)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for page, want := range map[string]int{"1": 1, "2": 1, "3": 1, "4": 0} {
+		if requested[page] != want {
+			t.Errorf("page %s requested %d times, want %d", page, requested[page], want)
+		}
+	}
+}
+
+func TestPrefetchSurvivesPanic(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(45, 20))
+	})
+	enablePrefetch(t)
+	old := searchNews
+	t.Cleanup(func() { searchNews = old })
+	searchNews = func(ctx context.Context, q newsQuery, pageSize, page int) (Results, error) {
+		if page > 1 {
+			panic("boom")
+		}
+		return getNews(ctx, q, pageSize, page)
+	}
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+	waitPrefetch(t)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxQueryLength = 500 // NewsAPI ограничивает длину q 500 символами
+
+// ErrInvalidQuery — параметр q не прошел sanitizeQuery.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// sanitizeQuery — единая проверка параметра q перед поиском. Обрезает
+// пробелы, заменяет переводы строк и табуляцию пробелами и отклоняет
+// слишком длинные запросы, невалидный UTF-8, управляющие символы и символы
+// смены направления текста. Экранирование остается за html/template и
+// url.Values при сборке адреса NewsAPI; в Search.SearchKey попадает уже
+// проверенное значение.
+func sanitizeQuery(raw string) (string, error) {
+	if !utf8.ValidString(raw) {
+		return "", fmt.Errorf("%w: not valid UTF-8", ErrInvalidQuery)
+	}
+	q := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, raw))
+
+	if n := utf8.RuneCountInString(q); n > maxQueryLength {
+		return "", fmt.Errorf("%w: %d characters long, the maximum is %d", ErrInvalidQuery, n, maxQueryLength)
+	}
+	for _, r := range q {
+		if unicode.IsControl(r) || isBidiControl(r) {
+			return "", fmt.Errorf("%w: contains control character %U", ErrInvalidQuery, r)
+		}
+	}
+	return q, nil
+}
+
+// ErrUnbalancedQuotes — в запросе есть незакрытая фраза в кавычках.
+var ErrUnbalancedQuotes = fmt.Errorf("%w: unbalanced double quote", ErrInvalidQuery)
+
+// typographicQuotes — кавычки, которые подставляют телефоны и текстовые
+// редакторы; NewsAPI понимает фразы только в прямых кавычках.
+var typographicQuotes = strings.NewReplacer("\u201c", `"`, "\u201d", `"`, "\u201e", `"`, "\u00ab", `"`, "\u00bb", `"`)
+
+// normalizeQuery готовит к отправке в NewsAPI запрос с операторами
+// ("фраза", +слово, -слово, AND, OR, NOT): заменяет типографские кавычки
+// прямыми и схлопывает пробелы вне фраз, не трогая сами операторы. Запрос с
+// незакрытой кавычкой отклоняется с ErrUnbalancedQuotes — NewsAPI принял бы
+// его, но искал бы совсем не то. Экранирование — забота url.Values.
+func normalizeQuery(q string) (string, error) {
+	q = typographicQuotes.Replace(q)
+	if strings.Count(q, `"`)%2 != 0 {
+		return "", ErrUnbalancedQuotes
+	}
+
+	var b strings.Builder
+	inPhrase, space := false, false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			inPhrase = !inPhrase
+		case r == ' ' && !inPhrase:
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
+
+// queryErrorMessage — сообщение для пользователя об отклоненном запросе.
+func queryErrorMessage(err error) string {
+	if errors.Is(err, ErrUnbalancedQuotes) {
+		return "Invalid search query: a quoted phrase is not closed"
+	}
+	return "Invalid search query"
+}
+
+// isBidiControl — символы, меняющие направление текста: в выдаче ими можно
+// перевернуть соседний текст.
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// validateQuery проверяет поисковый запрос в синтаксисе NewsAPI и возвращает
+// список найденных проблем. Пустой список означает, что запрос корректен.
+func validateQuery(q string) []string {
+	var messages []string
+
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return []string{"Query is empty"}
+	}
+	if n := len([]rune(q)); n > maxQueryLength {
+		messages = append(messages, fmt.Sprintf("Query is %d characters long, the maximum is %d", n, maxQueryLength))
+	}
+	if strings.Count(q, `"`)%2 != 0 {
+		messages = append(messages, "Query has an unbalanced double quote")
+	}
+
+	depth := 0
+	for _, r := range q {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		messages = append(messages, "Query has unbalanced parentheses")
+	}
+
+	tokens := strings.Fields(q)
+	prevOperator := true // Запрос не может начинаться с оператора
+	for _, tok := range tokens {
+		switch {
+		case isBooleanOperator(tok):
+			if prevOperator {
+				messages = append(messages, fmt.Sprintf("Operator %s must follow a search term", tok))
+			}
+			prevOperator = true
+			continue
+		case tok == "+" || tok == "-":
+			messages = append(messages, fmt.Sprintf("Operator %s must be attached to a search term", tok))
+		}
+		prevOperator = false
+	}
+	if len(tokens) > 0 && isBooleanOperator(tokens[len(tokens)-1]) {
+		messages = append(messages, "Query cannot end with an operator")
+	}
+
+	return messages
+}
+
+func isBooleanOperator(tok string) bool {
+	return tok == "AND" || tok == "OR" || tok == "NOT"
+}
+
+// queryTerms возвращает слова запроса в нижнем регистре без кавычек,
+// скобок, операторов и исключенных через "-" слов.
+func queryTerms(q string) []string {
+	var terms []string
+	for _, tok := range strings.Fields(q) {
+		if isBooleanOperator(tok) || strings.HasPrefix(tok, "-") {
+			continue
+		}
+		if tok = strings.ToLower(strings.Trim(tok, `"+()`)); tok != "" {
+			terms = append(terms, tok)
+		}
+	}
+	return terms
+}
+
+// highlightTerms экранирует text и оборачивает в <mark> вхождения слов terms
+// без учета регистра. Разметка добавляется только вокруг уже экранированных
+// совпадений, поэтому результат безопасно выводить как HTML.
+func highlightTerms(text string, terms []string) template.HTML {
+	if len(terms) == 0 || text == "" {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+	return template.HTML(b.String())
+}
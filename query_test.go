@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"plain", "golang", "golang", true},
+		{"trimmed", "  climate change \n", "climate change", true},
+		{"tabs and newlines", "go\tlang\r\nnews", "go lang  news", true},
+		{"script payload passes through for escaping", `<script>alert("x")</script>`, `<script>alert("x")</script>`, true},
+		{"attribute breaking", `"><img src=x onerror=alert(1)>`, `"><img src=x onerror=alert(1)>`, true},
+		{"cyrillic", "новости", "новости", true},
+		{"at the length limit", strings.Repeat("я", maxQueryLength), strings.Repeat("я", maxQueryLength), true},
+		{"over the length limit", strings.Repeat("a", maxQueryLength+1), "", false},
+		{"NUL", "go\x00lang", "", false},
+		{"escape sequence", "go\x1b[31mlang", "", false},
+		{"DEL", "go\x7flang", "", false},
+		{"C1 control", "go\u0085lang", "", false},
+		{"bidi override", "abc\u202edcba", "", false},
+		{"bidi isolate", "abc\u2067dcba", "", false},
+		{"invalid UTF-8", "go\xfflang", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeQuery(tt.raw)
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("sanitizeQuery(%q) = %q, %v; want ErrInvalidQuery", tt.raw, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("sanitizeQuery(%q) = %q, %v; want %q", tt.raw, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string // Подстрока первого сообщения; пусто — запрос корректен
+	}{
+		{`golang`, ""},
+		{`"climate change" AND (policy OR law)`, ""},
+		{`+bitcoin -ethereum`, ""},
+		{`   `, "empty"},
+		{strings.Repeat("a", maxQueryLength+1), "maximum is 500"},
+		{`"climate change`, "double quote"},
+		{`(policy OR law`, "parentheses"},
+		{`policy) OR (law`, "parentheses"},
+		{`AND golang`, "must follow a search term"},
+		{`golang OR`, "cannot end with an operator"},
+		{`bitcoin - ethereum`, "attached to a search term"},
+	}
+	for _, tt := range tests {
+		messages := validateQuery(tt.q)
+		if tt.want == "" {
+			if len(messages) != 0 {
+				t.Errorf("validateQuery(%q) = %v, want no messages", tt.q, messages)
+			}
+			continue
+		}
+		if len(messages) == 0 || !strings.Contains(messages[0], tt.want) {
+			t.Errorf("validateQuery(%q) = %v, want a message containing %q", tt.q, messages, tt.want)
+		}
+	}
+}
+
+func TestSearchPageEscapesQuery(t *testing.T) {
+	stubNewsAPI(t, `{"status":"ok","totalResults":1,"articles":[{"title":"Story about <script> tags","url":"https://example.com/1","publishedAt":"2024-05-01T10:00:00Z"}]}`)
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q="+url.QueryEscape(`<script>alert(1)</script>`), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "<script>") {
+		t.Errorf("query or title rendered unescaped:\n%s", body)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`"electric vehicles" AND battery -tesla`, `"electric vehicles" AND battery -tesla`},
+		{`+bitcoin OR ethereum NOT (scam OR fraud)`, `+bitcoin OR ethereum NOT (scam OR fraud)`},
+		{`climate   change    policy`, `climate change policy`},
+		{`"two  spaces   kept"   outside`, `"two  spaces   kept" outside`},
+		{`“smart quotes” AND «guillemets»`, `"smart quotes" AND "guillemets"`},
+		{`""`, `""`},
+	}
+	for _, tt := range tests {
+		got, err := normalizeQuery(tt.in)
+		if err != nil {
+			t.Errorf("normalizeQuery(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeQueryUnbalancedQuotes(t *testing.T) {
+	for _, in := range []string{`"electric vehicles AND battery`, `a "b" "c`, `“open`, `"`} {
+		_, err := normalizeQuery(in)
+		if !errors.Is(err, ErrUnbalancedQuotes) || !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("normalizeQuery(%q) error = %v, want ErrUnbalancedQuotes", in, err)
+		}
+	}
+}
+
+func TestSearchAdvancedQuery(t *testing.T) {
+	var sent string
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		sent = r.URL.Query().Get("q")
+		w.Write([]byte(articlesJSON(45, 20)))
+	})
+
+	q := `"electric vehicles" AND battery -tesla`
+	rec := httptest.NewRecorder()
+	search, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=%22electric+vehicles%22++AND+battery+-tesla", nil), false)
+	if !ok {
+		t.Fatalf("runSearch failed: %d %s", rec.Code, rec.Body)
+	}
+	if sent != q {
+		t.Errorf("NewsAPI q = %q, want %q", sent, q)
+	}
+	if search.SearchKey != q {
+		t.Errorf("SearchKey = %q, want %q", search.SearchKey, q)
+	}
+	if got, want := search.PageURL(2), "q=%22electric+vehicles%22+AND+battery+-tesla"; !strings.Contains(got, want) {
+		t.Errorf("PageURL(2) = %q, want it to contain %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=%22electric+vehicles", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unbalanced quote: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
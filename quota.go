@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExhausted — квота NewsAPI исчерпана до момента сброса.
+var ErrQuotaExhausted = fmt.Errorf("%w: quota exhausted", ErrRateLimited)
+
+// QuotaStatus — последнее известное состояние квоты NewsAPI.
+type QuotaStatus struct {
+	Known     bool      `json:"known"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"` // Нулевое, если NewsAPI не прислал X-RateLimit-Reset
+	UpdatedAt time.Time `json:"updatedAt"`
+	Throttled bool      `json:"throttled"`
+}
+
+var (
+	quotaLow       = 10          // Порог остатка квоты, ниже которого запросы замедляются
+	quotaSlowDelay = time.Second // Задержка перед запросом при низком остатке
+)
+
+// quotaState отслеживает X-RateLimit-* заголовки ответов NewsAPI для одного
+// ключа и притормаживает запросы, когда остаток квоты подходит к нулю.
+type quotaState struct {
+	mu        sync.Mutex
+	low       int           // Порог, ниже которого запросы замедляются
+	slowDelay time.Duration // Задержка перед запросом при низком остатке
+	status    QuotaStatus
+}
+
+// update сохраняет остаток и время сброса квоты. Если заголовков нет,
+// прежнее состояние не меняется.
+func (q *quotaState) update(h http.Header, now time.Time) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.status.Known = true
+	q.status.Remaining = remaining
+	q.status.UpdatedAt = now
+	q.status.Reset = parseRateLimitReset(h.Get("X-RateLimit-Reset"), now)
+}
+
+// parseRateLimitReset понимает как unix-время, так и число секунд до сброса.
+func parseRateLimitReset(value string, now time.Time) time.Time {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}
+	}
+	if n > 1_000_000_000 {
+		return time.Unix(n, 0)
+	}
+	return now.Add(time.Duration(n) * time.Second)
+}
+
+// resetAt — когда квота сбросится. Без X-RateLimit-Reset остаток считается
+// устаревшим через keyCooldown после последнего ответа: иначе нулевой
+// остаток блокировал бы запросы навсегда. Вызывается под q.mu.
+func (q *quotaState) resetAt() time.Time {
+	if q.status.Reset.IsZero() {
+		return q.status.UpdatedAt.Add(keyCooldown)
+	}
+	return q.status.Reset
+}
+
+// throttle возвращает задержку перед очередным запросом к NewsAPI или
+// ErrQuotaExhausted, если квота исчерпана и время сброса еще не наступило.
+func (q *quotaState) throttle(now time.Time) (time.Duration, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := &q.status
+	if !s.Known {
+		return 0, nil
+	}
+	if !now.Before(q.resetAt()) {
+		s.Known = false // Квота сброшена, ждем свежих заголовков
+		s.Throttled = false
+		return 0, nil
+	}
+
+	s.Throttled = s.Remaining <= q.low
+	switch {
+	case s.Remaining <= 0:
+		return 0, ErrQuotaExhausted
+	case s.Throttled:
+		return q.slowDelay, nil
+	}
+	return 0, nil
+}
+
+// retryAfter возвращает число секунд до сброса квоты (не меньше 1).
+func (q *quotaState) retryAfter(now time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if secs := int(q.resetAt().Sub(now).Seconds()); secs > 0 {
+		return secs
+	}
+	return 1
+}
+
+// Status возвращает копию текущего состояния квоты.
+func (q *quotaState) Status() QuotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.status
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitReset(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"", time.Time{}},
+		{"soon", time.Time{}},
+		{"-5", time.Time{}},
+		{"90", now.Add(90 * time.Second)},           // Секунды до сброса
+		{"1700003600", time.Unix(1_700_003_600, 0)}, // Unix-время сброса
+	}
+	for _, tt := range tests {
+		if got := parseRateLimitReset(tt.value, now); !got.Equal(tt.want) {
+			t.Errorf("parseRateLimitReset(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQuotaThrottle(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	q := &quotaState{low: 10, slowDelay: time.Second}
+	headers := func(remaining, reset string) http.Header {
+		h := http.Header{}
+		h.Set("X-RateLimit-Remaining", remaining)
+		h.Set("X-RateLimit-Reset", reset)
+		return h
+	}
+
+	if wait, err := q.throttle(now); wait != 0 || err != nil {
+		t.Fatalf("unknown quota: throttle = %v, %v; want no delay", wait, err)
+	}
+
+	q.update(headers("50", "3600"), now)
+	if wait, err := q.throttle(now); wait != 0 || err != nil {
+		t.Errorf("plenty left: throttle = %v, %v; want no delay", wait, err)
+	}
+
+	q.update(headers("5", "3600"), now)
+	if wait, err := q.throttle(now); wait != time.Second || err != nil {
+		t.Errorf("low quota: throttle = %v, %v; want 1s", wait, err)
+	}
+	if s := q.Status(); !s.Known || s.Remaining != 5 || !s.Throttled {
+		t.Errorf("Status = %+v, want known, 5 remaining, throttled", s)
+	}
+
+	q.update(http.Header{}, now)
+	if s := q.Status(); s.Remaining != 5 {
+		t.Errorf("response without headers changed Remaining to %d", s.Remaining)
+	}
+
+	q.update(headers("0", "3600"), now)
+	if _, err := q.throttle(now.Add(time.Minute)); !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("exhausted quota: err = %v, want ErrQuotaExhausted", err)
+	}
+	if got := q.retryAfter(now.Add(time.Minute)); got != 3540 {
+		t.Errorf("retryAfter = %d, want 3540", got)
+	}
+
+	if wait, err := q.throttle(now.Add(time.Hour)); wait != 0 || err != nil {
+		t.Errorf("after reset: throttle = %v, %v; want no delay", wait, err)
+	}
+	if s := q.Status(); s.Known || s.Throttled {
+		t.Errorf("Status after reset = %+v, want unknown and not throttled", s)
+	}
+}
+
+func TestQuotaThrottleWithoutReset(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	q := &quotaState{low: 10, slowDelay: time.Second}
+	h := http.Header{}
+	h.Set("X-RateLimit-Remaining", "0")
+	q.update(h, now)
+
+	if _, err := q.throttle(now.Add(time.Minute)); !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("exhausted quota: err = %v, want ErrQuotaExhausted", err)
+	}
+	if got, want := q.retryAfter(now.Add(time.Minute)), int((keyCooldown - time.Minute).Seconds()); got != want {
+		t.Errorf("retryAfter = %d, want %d", got, want)
+	}
+	if wait, err := q.throttle(now.Add(keyCooldown)); wait != 0 || err != nil {
+		t.Errorf("after cooldown: throttle = %v, %v; want no delay", wait, err)
+	}
+	if s := q.Status(); s.Known {
+		t.Errorf("Status after cooldown = %+v, want unknown", s)
+	}
+}
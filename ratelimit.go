@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var trustProxy bool // Брать IP клиента из X-Forwarded-For (только за своим прокси)
+
+const maxTrackedClients = 10000 // Предел числа корзин, чтобы поток новых IP не съел память
+
+// rateLimiter — token bucket на каждый IP: корзина вмещает perMinute
+// запросов и пополняется равномерно в течение минуты.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, buckets: make(map[string]*tokenBucket)}
+}
+
+// allow списывает запрос с корзины ip. Если корзина пуста, возвращает false
+// и время, через которое появится следующий токен.
+func (l *rateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	capacity := float64(l.perMinute)
+	perSecond := capacity / 60
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxTrackedClients {
+			l.prune(now)
+		}
+		if len(l.buckets) >= maxTrackedClients {
+			return true, 0 // Лучше пропустить, чем расти без предела
+		}
+		b = &tokenBucket{tokens: capacity, last: now}
+		l.buckets[ip] = b
+	}
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// prune удаляет корзины, которые успели наполниться до краев: их клиенты
+// минуту не приходили, и новая корзина для них ничем не отличается.
+func (l *rateLimiter) prune(now time.Time) {
+	for ip, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// limitRequests отвечает 429 с Retry-After клиентам, превысившим лимит.
+// Статика, проверки здоровья, /metrics и /stats не ограничиваются; nil-лимитер
+// отключает ограничение целиком.
+func limitRequests(limiter *rateLimiter, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/assets/"), r.URL.Path == "/healthz", r.URL.Path == "/readyz", r.URL.Path == "/metrics", r.URL.Path == "/stats":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait := limiter.allow(clientIP(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests, slow down", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
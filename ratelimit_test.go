@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("203.0.113.7", now); !ok {
+			t.Fatalf("request %d within the limit was refused", i+1)
+		}
+	}
+	ok, wait := l.allow("203.0.113.7", now)
+	if ok || wait != 20*time.Second {
+		t.Fatalf("request over the limit: ok = %v, wait = %v; want refused for 20s", ok, wait)
+	}
+	if ok, _ := l.allow("198.51.100.1", now); !ok {
+		t.Error("another client shares the bucket")
+	}
+
+	if ok, _ := l.allow("203.0.113.7", now.Add(10*time.Second)); ok {
+		t.Error("a token came back before a third of a minute")
+	}
+	if ok, _ := l.allow("203.0.113.7", now.Add(30*time.Second)); !ok {
+		t.Error("no token after 20s of refill")
+	}
+
+	later := now.Add(5 * time.Minute)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("203.0.113.7", later); !ok {
+			t.Fatalf("after a long pause request %d was refused; the bucket must refill to capacity", i+1)
+		}
+	}
+	if ok, _ := l.allow("203.0.113.7", later); ok {
+		t.Error("an idle bucket refilled above its capacity")
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newRateLimiter(1)
+	l.allow("stale", now)
+	l.allow("active", now.Add(50*time.Second))
+
+	l.prune(now.Add(time.Minute))
+	if _, ok := l.buckets["stale"]; ok {
+		t.Error("bucket idle for a minute was kept")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Error("recently used bucket was pruned")
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := limitRequests(newRateLimiter(1), next)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "203.0.113.7:51234"
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := get("/search?q=go"); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec := get("/search?q=go")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60 with one request per minute", got)
+	}
+	for _, path := range []string{"/healthz", "/readyz", "/metrics", "/stats", "/assets/style.css"} {
+		if rec := get(path); rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want it exempt from the limit", path, rec.Code)
+		}
+	}
+
+	handler = limitRequests(nil, next)
+	for i := 0; i < 3; i++ {
+		if rec := get("/search?q=go"); rec.Code != http.StatusOK {
+			t.Fatalf("without a limiter request %d: status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+}
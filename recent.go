@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	recentSearchesCookie  = "recent"
+	maxRecentQueryLength  = 100 // Более длинные запросы не запоминаем, чтобы cookie оставалась маленькой
+	recentSearchesMaxAge  = 30 * 24 * 60 * 60
+	cookieSignatureLength = 16 // Байт HMAC-SHA256, которые кладем в cookie
+)
+
+var maxRecentSearches = 5 // Сколько последних запросов помнить; 0 отключает
+
+var cookieSecret []byte // Ключ HMAC для подписи cookie; задается -cookiesecret
+
+// newCookieSecret возвращает случайный ключ на случай, если -cookiesecret не
+// задан. Подписанные им cookie перестанут приниматься после перезапуска.
+func newCookieSecret() []byte {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return b
+}
+
+// recentSearches возвращает запросы из подписанной cookie посетителя, от
+// новых к старым. Подделанная или испорченная cookie считается пустой.
+func recentSearches(r *http.Request) []string {
+	if maxRecentSearches <= 0 {
+		return nil
+	}
+	var queries []string
+	if !readSignedCookie(r, recentSearchesCookie, &queries) {
+		return nil
+	}
+	if len(queries) > maxRecentSearches {
+		queries = queries[:maxRecentSearches]
+	}
+	return queries
+}
+
+// rememberable сообщает, попадет ли query в список недавних запросов: пустые
+// и слишком длинные запросы не запоминаются.
+func rememberable(query string) bool {
+	return maxRecentSearches > 0 && query != "" && len(query) <= maxRecentQueryLength
+}
+
+// withRecentSearch возвращает список недавних запросов queries после поиска
+// query: query в начале, без повтора (без учета регистра), не длиннее
+// maxRecentSearches.
+func withRecentSearch(queries []string, query string) []string {
+	if !rememberable(query) {
+		return queries
+	}
+
+	updated := []string{query}
+	for _, q := range queries {
+		if len(updated) < maxRecentSearches && !strings.EqualFold(q, query) {
+			updated = append(updated, q)
+		}
+	}
+	return updated
+}
+
+// rememberSearch добавляет query в список недавних запросов (см.
+// withRecentSearch) и сохраняет его в cookie. Возвращает новый список.
+// Вызывать только для поиска, который удался.
+func rememberSearch(w http.ResponseWriter, r *http.Request, query string) []string {
+	queries := recentSearches(r)
+	if !rememberable(query) {
+		return queries
+	}
+
+	updated := withRecentSearch(queries, query)
+	setSignedCookie(w, r, recentSearchesCookie, signedCookieValue(recentSearchesCookie, updated), recentSearchesMaxAge)
+	return updated
+}
+
+// signedCookieValue кодирует v в JSON и base64 и добавляет подпись HMAC.
+func signedCookieValue(name string, v any) string {
+	data, _ := json.Marshal(v)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + signCookie(name, payload)
+}
+
+func setSignedCookie(w http.ResponseWriter, r *http.Request, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// readSignedCookie разбирает в v cookie, записанную signedCookieValue.
+// Возвращает false, если cookie нет, подпись не сошлась или JSON испорчен.
+func readSignedCookie(r *http.Request, name string, v any) bool {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signCookie(name, payload))) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+func signCookie(name, payload string) string {
+	mac := hmac.New(sha256.New, cookieSecret)
+	mac.Write([]byte(name + "=" + payload)) // Имя cookie в подписи: значение не переносится в другую cookie
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:cookieSignatureLength])
+}
+
+// otherSearches — недавние запросы без текущего, для быстрых ссылок.
+func otherSearches(queries []string, current string) []string {
+	var out []string
+	for _, q := range queries {
+		if !strings.EqualFold(q, current) {
+			out = append(out, q)
+		}
+	}
+	return out
+}
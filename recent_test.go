@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRecentSearchesOnlyAfterSuccess(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "broken" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":"error","code":"parameterInvalid","message":"bad query"}`))
+			return
+		}
+		w.Write([]byte(articlesJSON(1, 1)))
+	})
+
+	var cookies []*http.Cookie
+	search := func(target string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		searchHandler(rec, req)
+		for _, c := range rec.Result().Cookies() {
+			if c.Name == recentSearchesCookie {
+				cookies = []*http.Cookie{c}
+			}
+		}
+		return rec.Code
+	}
+	recent := func() []string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		return recentSearches(req)
+	}
+
+	if code := search("/search?q=golang"); code != http.StatusOK {
+		t.Fatalf("search: status = %d, want 200", code)
+	}
+	if code := search("/search?q=broken"); code != http.StatusBadRequest {
+		t.Fatalf("failed search: status = %d, want 400", code)
+	}
+	search("/search?q=golang&page=abc")
+	if got := recent(); !reflect.DeepEqual(got, []string{"golang"}) {
+		t.Errorf("recent searches = %v, want only the successful [golang]", got)
+	}
+
+	search("/search?q=rust")
+	if got := recent(); !reflect.DeepEqual(got, []string{"rust", "golang"}) {
+		t.Errorf("recent searches = %v, want [rust golang]", got)
+	}
+}
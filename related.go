@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxRelated         = 5 // Сколько похожих статей показывать справа от выдачи
+	maxRelatedKeywords = 3
+	minKeywordLength   = 3 // Более короткие слова почти всегда служебные
+)
+
+var showRelated bool // Искать ли похожие статьи; это второй запрос к NewsAPI на каждый поиск
+
+// stopwords — служебные слова, которые не годятся в ключевые.
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true, "that": true, "this": true,
+	"into": true, "over": true, "after": true, "about": true, "are": true, "was": true, "were": true,
+	"has": true, "have": true, "had": true, "will": true, "its": true, "his": true, "her": true,
+	"their": true, "they": true, "you": true, "not": true, "but": true, "out": true, "new": true,
+	"says": true, "said": true, "how": true, "why": true, "what": true, "who": true, "when": true,
+	"как": true, "что": true, "это": true, "для": true, "при": true, "или": true, "его": true,
+	"она": true, "они": true, "так": true, "уже": true, "после": true, "над": true, "под": true,
+}
+
+// relatedKeywords выбирает из заголовка title значимые слова: без служебных,
+// коротких и уже входящих в запрос. Слова упорядочены по тому, как часто они
+// встречаются в заголовках и описаниях всей выдачи, при равенстве — по
+// порядку в заголовке.
+func relatedKeywords(title string, articles []Article, query string) []string {
+	skip := map[string]bool{}
+	for _, term := range queryTerms(query) {
+		skip[term] = true
+	}
+
+	var keywords []string
+	seen := map[string]bool{}
+	for _, w := range words(title) {
+		if !seen[w] && !skip[w] && !stopwords[w] && utf8.RuneCountInString(w) >= minKeywordLength {
+			seen[w] = true
+			keywords = append(keywords, w)
+		}
+	}
+
+	freq := map[string]int{}
+	for _, a := range articles {
+		for _, w := range words(a.Title + " " + a.Description) {
+			if seen[w] {
+				freq[w]++
+			}
+		}
+	}
+	sort.SliceStable(keywords, func(i, j int) bool { return freq[keywords[i]] > freq[keywords[j]] })
+
+	if len(keywords) > maxRelatedKeywords {
+		keywords = keywords[:maxRelatedKeywords]
+	}
+	return keywords
+}
+
+// words разбивает text на слова в нижнем регистре.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// startRelated ищет статьи, похожие на первую статью выдачи, в отдельной
+// горутине: поиск зависит от основной выдачи, но идет параллельно с ее
+// обработкой. Возвращенная функция дожидается результата и убирает статьи,
+// которые уже есть в итоговой выдаче. Ошибка поиска только пишется в журнал:
+// без похожих статей страница все равно нужна.
+func startRelated(ctx context.Context, search *Search, results Results) func(shown []Article) []Article {
+	if len(results.Articles) == 0 {
+		return func([]Article) []Article { return nil }
+	}
+	keywords := relatedKeywords(results.Articles[0].Title, results.Articles, search.SearchKey)
+	if len(keywords) == 0 {
+		return func([]Article) []Article { return nil }
+	}
+
+	q := newsQuery{Query: strings.Join(keywords, " "), SortBy: "relevancy", Language: search.Language}
+	var related []Article
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		res, err := searchNews(ctx, q, 2*maxRelated, 1)
+		if err != nil {
+			slog.WarnContext(ctx, "Related articles unavailable", "keywords", q.Query, "error", err)
+			return
+		}
+		related = res.Articles
+	}()
+
+	return func(shown []Article) []Article {
+		wg.Wait()
+		seen := map[string]bool{}
+		for _, a := range shown {
+			seen[a.URL] = true
+		}
+		var out []Article
+		for _, a := range related {
+			if len(out) < maxRelated && a.URL != "" && !seen[a.URL] {
+				seen[a.URL] = true
+				out = append(out, a)
+			}
+		}
+		return out
+	}
+}
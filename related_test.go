@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRelatedKeywords(t *testing.T) {
+	articles := []Article{
+		{Title: "Mars rover finds ancient lake on Mars", Description: "NASA rover data"},
+		{Title: "NASA budget talks", Description: "The rover program is safe"},
+		{Title: "Lake levels", Description: "Drought"},
+	}
+	got := relatedKeywords(articles[0].Title, articles, "mars")
+	want := []string{"rover", "lake", "finds"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("relatedKeywords = %v, want %v", got, want)
+	}
+
+	if got := relatedKeywords("The and of it", articles, ""); len(got) != 0 {
+		t.Errorf("relatedKeywords of stopwords = %v, want none", got)
+	}
+}
+
+func TestRunSearchRelated(t *testing.T) {
+	if err := loadTemplates(); err != nil {
+		t.Fatalf("loadTemplates: %v", err)
+	}
+	oldNews, oldShow := searchNews, showRelated
+	showRelated = true
+	t.Cleanup(func() { searchNews, showRelated = oldNews, oldShow })
+
+	primary := Results{Status: "ok", TotalResults: 2, Articles: []Article{
+		{Title: "Volcano erupts near Reykjavik", URL: "https://example.com/main/1"},
+		{Title: "Volcano ash closes airports", URL: "https://example.com/main/2"},
+	}}
+	var relatedQuery string
+	var relatedErr error
+	searchNews = func(ctx context.Context, q newsQuery, pageSize, page int) (Results, error) {
+		if q.Query == "iceland" {
+			return primary, nil
+		}
+		relatedQuery = q.Query
+		if relatedErr != nil {
+			return Results{}, relatedErr
+		}
+		res := Results{Status: "ok", Articles: []Article{{Title: "Duplicate", URL: "https://example.com/main/1"}}}
+		for i := 0; i < 8; i++ {
+			res.Articles = append(res.Articles, Article{Title: fmt.Sprintf("Related %d", i), URL: fmt.Sprintf("https://example.com/related/%d", i)})
+		}
+		return res, nil
+	}
+
+	search, ok := runSearch(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=iceland", nil), false)
+	if !ok {
+		t.Fatal("runSearch failed")
+	}
+	if relatedQuery != "volcano erupts near" {
+		t.Errorf("related query = %q, want the top title keywords", relatedQuery)
+	}
+	if got := titles(search.Related); !reflect.DeepEqual(got, []string{"Related 0", "Related 1", "Related 2", "Related 3", "Related 4"}) {
+		t.Errorf("Related = %v, want 5 articles not in the main results", got)
+	}
+
+	relatedErr = errors.New("quota exhausted")
+	search, ok = runSearch(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/search?q=iceland", nil), false)
+	if !ok || len(search.Results.Articles) != 2 {
+		t.Fatalf("runSearch with failing related search: ok = %t, want the main results", ok)
+	}
+	if len(search.Related) != 0 {
+		t.Errorf("Related = %v, want none after an error", titles(search.Related))
+	}
+}
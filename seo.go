@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var allowCrawling = true // false — robots.txt запрещает поисковикам обходить сайт
+
+var assetsDir = "assets" // Каталог статики для /assets/ и /favicon.ico
+
+var publicSiteURL string // Внешний адрес сайта из -siteurl для sitemap, RSS и канонических ссылок
+
+// sitemapURLSet — документ sitemap.xml.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+}
+
+// robotsHandler отдает robots.txt. Служебные адреса закрыты всегда, остальное —
+// в зависимости от -allowcrawling.
+func robotsHandler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if allowCrawling {
+		b.WriteString("Disallow: /admin/\nDisallow: /out\nDisallow: /api/\n")
+		if site := siteURL(r); site != "" {
+			fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", site)
+		}
+	} else {
+		b.WriteString("Disallow: /\n")
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write([]byte(b.String()))
+}
+
+// sitemapHandler перечисляет главную и вкладки главных новостей — страницы с
+// постоянными адресами. Результаты поиска в карту сайта не входят. Без
+// -siteurl карта не отдается: в ней нужны абсолютные адреса.
+func sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	site := siteURL(r)
+	if site == "" {
+		http.NotFound(w, r)
+		return
+	}
+	set := sitemapURLSet{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs: []sitemapURL{
+			{Loc: site + "/", ChangeFreq: "daily"},
+			{Loc: site + "/headlines", ChangeFreq: "hourly"},
+		},
+	}
+	for _, category := range newsCategories {
+		set.URLs = append(set.URLs, sitemapURL{Loc: site + "/headlines?category=" + category, ChangeFreq: "hourly"})
+	}
+
+	body, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding sitemap: %v", err)
+		http.Error(w, "Failed to encode sitemap", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+}
+
+// faviconHandler отдает favicon.ico из каталога статики.
+func faviconHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/x-icon")
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	http.ServeFile(w, r, filepath.Join(assetsDir, "favicon.ico"))
+}
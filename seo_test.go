@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSiteURLIgnoresHostHeader(t *testing.T) {
+	oldSite, oldDev := publicSiteURL, devMode
+	defer func() { publicSiteURL, devMode = oldSite, oldDev }()
+
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Host = "evil.example"
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+		return rec
+	}
+
+	publicSiteURL, devMode = "https://news.example.com", false
+	if body := get(robotsHandler, "/robots.txt").Body.String(); !strings.Contains(body, "Sitemap: https://news.example.com/sitemap.xml") {
+		t.Errorf("robots.txt = %q, want the -siteurl sitemap", body)
+	}
+	rec := get(sitemapHandler, "/sitemap.xml")
+	if body := rec.Body.String(); rec.Code != http.StatusOK || strings.Contains(body, "evil.example") || !strings.Contains(body, "<loc>https://news.example.com/headlines</loc>") {
+		t.Errorf("sitemap: status = %d, body = %q; want -siteurl locations", rec.Code, body)
+	}
+	s := Search{SearchKey: "go"}
+	s.setMeta(httptest.NewRequest(http.MethodGet, "http://evil.example/search?q=go", nil))
+	if !strings.HasPrefix(s.CanonicalURL, "https://news.example.com/search?") {
+		t.Errorf("CanonicalURL = %q, want it under -siteurl", s.CanonicalURL)
+	}
+
+	publicSiteURL = ""
+	if body := get(robotsHandler, "/robots.txt").Body.String(); strings.Contains(body, "Sitemap:") {
+		t.Errorf("robots.txt without -siteurl = %q, want no Sitemap line", body)
+	}
+	if rec := get(sitemapHandler, "/sitemap.xml"); rec.Code != http.StatusNotFound {
+		t.Errorf("sitemap without -siteurl: status = %d, want 404", rec.Code)
+	}
+	if got := siteURL(httptest.NewRequest(http.MethodGet, "http://evil.example/", nil)); got != "" {
+		t.Errorf("siteURL without -siteurl = %q, want empty", got)
+	}
+
+	devMode = true
+	if got := siteURL(httptest.NewRequest(http.MethodGet, "http://localhost:8080/", nil)); got != "http://localhost:8080" {
+		t.Errorf("siteURL in -dev = %q, want the request host", got)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sourcesCache — списки источников NewsAPI по фильтрам. Меняются они редко,
+// поэтому живут дольше ответов поиска.
+var sourcesCache = newTTLCache[[]SourceInfo](6*time.Hour, 100)
+
+// SourceInfo — источник из /v2/top-headlines/sources.
+type SourceInfo struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Category    string `json:"category"`
+	Language    string `json:"language"`
+	Country     string `json:"country"`
+}
+
+// sourcesResponse — тело ответа /v2/top-headlines/sources; ошибки разбирает
+// decodeNewsAPIResponse.
+type sourcesResponse struct {
+	Sources []SourceInfo `json:"sources"`
+}
+
+// sourcesHandler отдает JSON-массив источников. Необязательные category,
+// lang и country передаются в NewsAPI как фильтры.
+func sourcesHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	category := params.Get("category")
+	language := params.Get("lang")
+	country := strings.ToLower(params.Get("country"))
+
+	switch {
+	case category != "" && !isNewsCategory(category):
+		writeJSONError(w, http.StatusBadRequest, "Unknown category")
+		return
+	case language != "" && !isNewsLanguage(language):
+		writeJSONError(w, http.StatusBadRequest, "Unsupported language")
+		return
+	case country != "" && !isNewsCountry(country):
+		writeJSONError(w, http.StatusBadRequest, "Unsupported country")
+		return
+	}
+
+	sources, err := getSources(r.Context(), category, language, country)
+	if errors.Is(err, context.Canceled) {
+		slog.InfoContext(r.Context(), "Client went away before sources arrived", "error", err)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting sources", "error", err)
+		status, msg := newsErrorStatus(w, err)
+		writeJSONError(w, status, msg)
+		return
+	}
+	writeJSON(w, http.StatusOK, sources)
+}
+
+// getSources запрашивает источники NewsAPI с учетом фильтров (пустые не
+// передаются) и кэширует ответ на sourcesCache.
+func getSources(ctx context.Context, category, language, country string) ([]SourceInfo, error) {
+	params := url.Values{}
+	if category != "" {
+		params.Set("category", category)
+	}
+	if language != "" {
+		params.Set("language", language)
+	}
+	if country != "" {
+		params.Set("country", country)
+	}
+	cacheKey := params.Encode()
+
+	if cached, ok := sourcesCache.Get(cacheKey); ok {
+		cacheLookups.Inc("sources", "hit")
+		return cached, nil
+	}
+	cacheLookups.Inc("sources", "miss")
+
+	resp, err := requestWithKeys(ctx, "top-headlines/sources", params)
+	if err != nil {
+		countUpstreamError(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var decoded sourcesResponse
+	if err := decodeNewsAPIResponse(ctx, resp, &decoded); err != nil {
+		return nil, err
+	}
+	if decoded.Sources == nil {
+		decoded.Sources = []SourceInfo{} // В JSON — [], а не null
+	}
+
+	sourcesCache.Set(cacheKey, decoded.Sources)
+	return decoded.Sources, nil
+}
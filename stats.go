@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+var startTime = time.Now()
+
+// StatsSummary — краткая сводка для /stats, накопленная с запуска.
+type StatsSummary struct {
+	UptimeSeconds     int64   `json:"uptimeSeconds"`
+	Requests          int64   `json:"requests"`
+	NewsAPICalls      int64   `json:"newsapiCalls"`
+	CacheHitRatio     float64 `json:"cacheHitRatio"`     // Доля попаданий в кэш ответов NewsAPI, 0..1
+	AvgUpstreamMillis float64 `json:"avgUpstreamMillis"` // Среднее время одного запроса к NewsAPI
+}
+
+// statsHandler отдает сводку в JSON. Числа берутся из тех же счетчиков,
+// что и /metrics, поэтому расходиться с ними не могут.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentStats(time.Now()))
+}
+
+func currentStats(now time.Time) StatsSummary {
+	hits, misses := cacheLookups.Sum("results", "hit"), cacheLookups.Sum("results", "miss")
+	calls, seconds := newsAPILatency.Totals()
+
+	stats := StatsSummary{
+		UptimeSeconds: int64(now.Sub(startTime).Seconds()),
+		Requests:      int64(httpRequests.Sum()),
+		NewsAPICalls:  int64(calls),
+	}
+	if hits+misses > 0 {
+		stats.CacheHitRatio = hits / (hits + misses)
+	}
+	if calls > 0 {
+		stats.AvgUpstreamMillis = seconds / float64(calls) * 1000
+	}
+	return stats
+}
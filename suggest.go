@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const maxSuggestions = 10
+
+// suggestMinCount — сколько раз запрос должны были искать, чтобы его
+// подсказывать другим: разовые запросы могут оказаться чьими-то личными.
+const suggestMinCount = 2
+
+// popularTopics дополняют подсказки, пока история поиска пуста.
+var popularTopics = []string{
+	"bitcoin", "business", "climate change", "covid", "economy", "elections",
+	"football", "health", "movies", "science", "space", "sports",
+	"stock market", "technology", "ukraine", "weather",
+}
+
+// suggestHandler отдает JSON-массив до 10 подсказок для префикса q.
+func suggestHandler(w http.ResponseWriter, r *http.Request) {
+	prefix, err := sanitizeQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid search query")
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestions(prefix, history.Entries(), maxSuggestions))
+}
+
+// suggestions подбирает запросы, начинающиеся с prefix без учета регистра:
+// сначала частые запросы из истории, затем популярные темы.
+func suggestions(prefix string, entries []historyEntry, limit int) []string {
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	out := []string{}
+	if prefix == "" {
+		return out
+	}
+
+	var matched []historyEntry
+	for _, e := range entries {
+		if e.Count >= suggestMinCount && strings.HasPrefix(e.Query, prefix) {
+			matched = append(matched, e)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Count > matched[j].Count })
+
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if len(out) < limit && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	for _, e := range matched {
+		add(e.Query)
+	}
+	for _, topic := range popularTopics {
+		if strings.HasPrefix(topic, prefix) {
+			add(topic)
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// templatePath — шаблон главной страницы. notfound.html, article.html,
+// error.html и maintenance.html лежат в том же каталоге.
+var templatePath = "index.html"
+
+var devMode bool // Перечитывать шаблоны на каждый запрос, чтобы правки были видны сразу
+
+var templatesMu sync.RWMutex // Защищает шаблоны страниц и templatesVersion, пока их перечитывает -dev
+
+var templatesVersion int64
+
+// siteTemplates — разобранные шаблоны всех страниц.
+type siteTemplates struct {
+	Index       *template.Template
+	NotFound    *template.Template
+	Article     *template.Template
+	Error       *template.Template
+	Maintenance *template.Template
+	Version     int64 // Меняется при каждой загрузке; входит в ETag страниц
+}
+
+// loadTemplates разбирает templatePath и шаблоны из того же каталога. Шаблоны
+// заменяются только вместе и только если все разобрались без ошибок.
+func loadTemplates() error {
+	dir := filepath.Dir(templatePath)
+	index, err := template.ParseFiles(templatePath)
+	if err != nil {
+		return err
+	}
+	notFound, err := template.ParseFiles(filepath.Join(dir, "notfound.html"))
+	if err != nil {
+		return err
+	}
+	article, err := template.ParseFiles(filepath.Join(dir, "article.html"))
+	if err != nil {
+		return err
+	}
+	errorPage, err := template.ParseFiles(filepath.Join(dir, "error.html"))
+	if err != nil {
+		return err
+	}
+	maintenancePage, err := template.ParseFiles(filepath.Join(dir, "maintenance.html"))
+	if err != nil {
+		return err
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	tpl, notFoundTpl, articleTpl, errorTpl, maintenanceTpl = index, notFound, article, errorPage, maintenancePage
+	templatesVersion = time.Now().UnixNano()
+	return nil
+}
+
+// templates возвращает текущие шаблоны. В режиме -dev они сначала
+// перечитываются с диска; при ошибке разбора остаются прежние.
+func templates() siteTemplates {
+	if devMode {
+		if err := loadTemplates(); err != nil {
+			log.Printf("Error reloading templates: %v", err)
+		}
+	}
+
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	return siteTemplates{Index: tpl, NotFound: notFoundTpl, Article: articleTpl, Error: errorTpl, Maintenance: maintenanceTpl, Version: templatesVersion}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+)
+
+const themeCookie = "theme"
+
+const defaultTheme = "auto" // Тема по настройкам системы посетителя
+
+// themes — допустимые значения cookie theme, в порядке выпадающего списка.
+var themes = []string{"auto", "light", "dark"}
+
+func isTheme(theme string) bool {
+	for _, t := range themes {
+		if t == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// requestTheme читает выбранную тему из cookie. Без cookie или с неизвестным
+// значением — defaultTheme. Класс темы ставится на <html> уже на сервере, так
+// что страница не мигает светлой темой до загрузки скриптов.
+func requestTheme(r *http.Request) string {
+	c, err := r.Cookie(themeCookie)
+	if err != nil || !isTheme(c.Value) {
+		return defaultTheme
+	}
+	return c.Value
+}
+
+// themeHandler сохраняет тему из формы в cookie и возвращает посетителя на
+// страницу, с которой он пришел.
+func themeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	theme := r.PostFormValue("theme")
+	if !isTheme(theme) {
+		renderError(w, r, http.StatusBadRequest, "Invalid theme: use light, dark or auto")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     themeCookie,
+		Value:    theme,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, backURL(r), http.StatusSeeOther)
+}
+
+// backURL — адрес страницы из Referer, если она на этом же сайте, иначе "/".
+func backURL(r *http.Request) string {
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		return ref.RequestURI()
+	}
+	return "/"
+}
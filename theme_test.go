@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestThemeHandler(t *testing.T) {
+	form := url.Values{"theme": {"dark"}}
+	req := httptest.NewRequest(http.MethodPost, "http://news.example/theme", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "http://news.example/search?q=go")
+	rec := httptest.NewRecorder()
+	themeHandler(rec, req)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusSeeOther)
+	}
+	if got := rec.Header().Get("Location"); got != "/search?q=go" {
+		t.Errorf("Location = %q, want /search?q=go", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != themeCookie || cookies[0].Value != "dark" {
+		t.Errorf("cookies = %v, want theme=dark", cookies)
+	}
+
+	for _, tt := range []struct {
+		method, theme string
+		status        int
+	}{
+		{http.MethodPost, "purple", http.StatusBadRequest},
+		{http.MethodGet, "dark", http.StatusMethodNotAllowed},
+	} {
+		req := httptest.NewRequest(tt.method, "/theme", strings.NewReader("theme="+tt.theme))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		themeHandler(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s theme=%s: status = %d, want %d", tt.method, tt.theme, rec.Code, tt.status)
+		}
+	}
+}
+
+func TestSearchPageTheme(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, articlesJSON(1, 1))
+	})
+
+	for cookie, want := range map[string]string{"": "auto", "light": "light", "dark": "dark", "neon": "auto"} {
+		req := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: themeCookie, Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		searchHandler(rec, req)
+		if !strings.Contains(rec.Body.String(), `class="theme-`+want+`"`) {
+			t.Errorf("theme cookie %q: page has no theme-%s class on <html>", cookie, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+var (
+	tlsCert string // PEM-сертификат; вместе с tlsKey включает HTTPS
+	tlsKey  string // Закрытый ключ к tlsCert
+
+	httpsRedirectAddr string // Адрес вроде ":80" для HTTP-сервера, который отправляет на HTTPS; пустой — не нужен
+)
+
+// checkTLSConfig проверяет, что сертификат и ключ заданы оба или ни одного,
+// а перенаправление на HTTPS включено только вместе с HTTPS.
+func checkTLSConfig(cert, key, redirectAddr string) error {
+	if (cert == "") != (key == "") {
+		return errors.New("-tlscert and -tlskey must be set together")
+	}
+	if redirectAddr != "" && cert == "" {
+		return errors.New("-httpsredirect needs -tlscert and -tlskey")
+	}
+	return nil
+}
+
+// httpsRedirectHandler отправляет любой запрос на тот же адрес по HTTPS на
+// порту httpsPort (443 в адрес не пишется).
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
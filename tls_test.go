@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckTLSConfig(t *testing.T) {
+	tests := []struct {
+		cert, key, redirect string
+		ok                  bool
+	}{
+		{"", "", "", true},
+		{"cert.pem", "key.pem", "", true},
+		{"cert.pem", "key.pem", ":80", true},
+		{"cert.pem", "", "", false},
+		{"", "key.pem", "", false},
+		{"", "", ":80", false},
+	}
+	for _, tt := range tests {
+		if err := checkTLSConfig(tt.cert, tt.key, tt.redirect); (err == nil) != tt.ok {
+			t.Errorf("checkTLSConfig(%q, %q, %q) = %v, want ok %t", tt.cert, tt.key, tt.redirect, err, tt.ok)
+		}
+	}
+}
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port, target, want string
+	}{
+		{"443", "http://news.example/search?q=go", "https://news.example/search?q=go"},
+		{"443", "http://news.example:80/", "https://news.example/"},
+		{"8443", "http://news.example/headlines?category=sports", "https://news.example:8443/headlines?category=sports"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		httpsRedirectHandler(tt.port).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tt.want {
+			t.Errorf("%s via port %s: %d %q, want 301 to %q", tt.target, tt.port, rec.Code, rec.Header().Get("Location"), tt.want)
+		}
+	}
+}
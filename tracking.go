@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams — параметры меток отслеживания, которые убираются из ссылок
+// на статьи; "*" в конце означает префикс. Задается -trackingparams.
+var trackingParams = []string{"utm_*", "fbclid", "gclid"}
+
+// parseTrackingParams разбирает значение -trackingparams: имена через
+// запятую без учета регистра.
+func parseTrackingParams(list string) []string {
+	var params []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range trackingParams {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CleanURL — адрес статьи без меток отслеживания из trackingParams.
+// Остальные параметры сохраняются как были, в том же порядке; адрес, который
+// не разбирается, возвращается без изменений.
+func (a *Article) CleanURL() string {
+	return stripTrackingParams(a.URL)
+}
+
+func stripTrackingParams(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+
+	var kept []string
+	removed := false
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		name, _, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if isTrackingParam(name) {
+			removed = true
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	if !removed {
+		return raw // Не пересобираем адрес: url.String мог бы поменять его запись
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	return u.String()
+}
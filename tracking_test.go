@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestArticleCleanURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.bbc.co.uk/news/world-123?at_medium=RSS&utm_source=newsapi&utm_campaign=feed", "https://www.bbc.co.uk/news/world-123?at_medium=RSS"},
+		{"https://example.com/story?id=42&fbclid=IwAR0abc_DEF&page=2", "https://example.com/story?id=42&page=2"},
+		{"https://example.com/a?UTM_Source=x&gclid=Cj0KCQ#comments", "https://example.com/a#comments"},
+		{"https://example.com/a?utm%5Fmedium=email&q=go%20lang", "https://example.com/a?q=go%20lang"},
+		{"https://example.com/search?q=utm_source&sort=new", "https://example.com/search?q=utm_source&sort=new"},
+		{"https://example.com/plain", "https://example.com/plain"},
+		{"https://example.com/a?b=1;c=2", "https://example.com/a?b=1;c=2"},
+		{"http://[::1:bad/?utm_source=x", "http://[::1:bad/?utm_source=x"}, // Не разбирается
+	}
+	for _, tt := range tests {
+		a := Article{URL: tt.url}
+		if got := a.CleanURL(); got != tt.want {
+			t.Errorf("CleanURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	old := trackingParams
+	trackingParams = parseTrackingParams(" Ref , mc_* ")
+	t.Cleanup(func() { trackingParams = old })
+	a := Article{URL: "https://example.com/a?ref=hn&mc_cid=1&utm_source=x"}
+	if got, want := a.CleanURL(), "https://example.com/a?utm_source=x"; got != want {
+		t.Errorf("CleanURL with custom params = %q, want %q", got, want)
+	}
+}
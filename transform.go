@@ -0,0 +1,58 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var exportWorkers = runtime.NumCPU() // Число горутин для подготовки статей к выгрузке
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// transformArticles применяет fn ко всем статьям, используя не больше workers
+// горутин. Порядок статей в результате совпадает с исходным.
+func transformArticles(articles []Article, workers int, fn func(Article) Article) []Article {
+	out := make([]Article, len(articles))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(articles) {
+		workers = len(articles)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				out[i] = fn(articles[i])
+			}
+		}()
+	}
+	for i := range articles {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return out
+}
+
+// cleanArticle убирает HTML-разметку и сущности из текстовых полей статьи и
+// метки отслеживания из ее адреса, как в ссылках на странице.
+func cleanArticle(a Article) Article {
+	a.URL = a.CleanURL()
+	a.Title = stripHTML(a.Title)
+	a.Description = stripHTML(a.Description)
+	a.Content = stripHTML(a.Content)
+	return a
+}
+
+func stripHTML(s string) string {
+	return strings.TrimSpace(html.UnescapeString(tagPattern.ReplaceAllString(s, "")))
+}
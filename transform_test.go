@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTransformArticlesMatchesSequential(t *testing.T) {
+	articles := make([]Article, 100)
+	for i := range articles {
+		articles[i] = Article{
+			Title:       fmt.Sprintf("<b>Story %d</b> &amp; more", i),
+			Description: fmt.Sprintf(" <p>About %d</p> ", i),
+			Content:     fmt.Sprintf("Text &lt;%d&gt;<br/>", i),
+			URL:         fmt.Sprintf("https://example.com/%d", i),
+		}
+	}
+
+	want := make([]Article, len(articles))
+	for i, a := range articles {
+		want[i] = cleanArticle(a)
+	}
+	if want[3].Title != "Story 3 & more" || want[3].Description != "About 3" || want[3].Content != "Text <3>" {
+		t.Fatalf("cleanArticle = %+v", want[3])
+	}
+
+	for _, workers := range []int{0, 1, 4, 16, 500} {
+		if got := transformArticles(articles, workers, cleanArticle); !reflect.DeepEqual(got, want) {
+			t.Errorf("transformArticles with %d workers differs from the sequential result", workers)
+		}
+	}
+	if got := transformArticles(nil, 4, cleanArticle); len(got) != 0 {
+		t.Errorf("transformArticles(nil) = %v, want empty", got)
+	}
+}
+
+func TestCleanArticleStripsTrackingParams(t *testing.T) {
+	a := cleanArticle(Article{URL: "https://example.com/story?id=7&utm_source=feed&fbclid=abc#top"})
+	if want := "https://example.com/story?id=7#top"; a.URL != want {
+		t.Errorf("URL = %q, want %q", a.URL, want)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trustSet — проверенные источники: id источников NewsAPI и домены.
+type trustSet map[string]bool
+
+// parseTrustSet разбирает список id и доменов через запятую.
+func parseTrustSet(list string) trustSet {
+	set := trustSet{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// IsTrusted сообщает, входит ли источник статьи в set: по id источника
+// или по домену ссылки, включая его поддомены.
+func (a *Article) IsTrusted(set trustSet) bool {
+	if len(set) == 0 {
+		return false
+	}
+	if a.Source.ID != "" && set[strings.ToLower(a.Source.ID)] {
+		return true
+	}
+
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if set[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+	return false
+}
+
+// filterTrusted оставляет только статьи из проверенных источников. Фильтр
+// работает на одной полученной странице: TotalResults остается числом
+// NewsAPI, а шаблон пишет, сколько статей осталось на этой странице.
+func filterTrusted(articles []Article, set trustSet) []Article {
+	out := articles[:0]
+	for i := range articles {
+		if articles[i].IsTrusted(set) {
+			out = append(out, articles[i])
+		}
+	}
+	return out
+}
+
+// pinTrusted поднимает статьи из проверенных источников над остальными.
+// Внутри каждой группы порядок сохраняется, так что отсортированная по
+// дате выдача остается отсортированной.
+func pinTrusted(articles []Article, set trustSet) []Article {
+	if len(set) == 0 {
+		return articles
+	}
+	trusted := make([]Article, 0, len(articles))
+	var rest []Article
+	for _, a := range articles {
+		if a.IsTrusted(set) {
+			trusted = append(trusted, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	return append(trusted, rest...)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func titles(articles []Article) []string {
+	out := make([]string, len(articles))
+	for i, a := range articles {
+		out[i] = a.Title
+	}
+	return out
+}
+
+func TestArticleIsTrusted(t *testing.T) {
+	set := parseTrustSet(" BBC-News, reuters.com ,,")
+	if len(set) != 2 {
+		t.Fatalf("parseTrustSet = %v, want bbc-news and reuters.com", set)
+	}
+
+	tests := []struct {
+		article Article
+		want    bool
+	}{
+		{Article{Source: Source{ID: "bbc-news"}, URL: "https://bbc.co.uk/1"}, true},
+		{Article{URL: "https://reuters.com/world"}, true},
+		{Article{URL: "https://www.Reuters.com/world"}, true},
+		{Article{URL: "https://notreuters.com/world"}, false},
+		{Article{URL: "https://reuters.com.evil.example/world"}, false},
+		{Article{Source: Source{ID: "cnn"}, URL: "https://cnn.com/1"}, false},
+		{Article{URL: "::not a url"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.article.IsTrusted(set); got != tt.want {
+			t.Errorf("IsTrusted(%v, %s) = %t, want %t", tt.article.Source.ID, tt.article.URL, got, tt.want)
+		}
+	}
+	if (&Article{URL: "https://reuters.com/"}).IsTrusted(nil) {
+		t.Error("IsTrusted with an empty set = true")
+	}
+}
+
+func TestFilterTrusted(t *testing.T) {
+	set := parseTrustSet("bbc-news,reuters.com")
+	articles := []Article{
+		{Title: "blog", URL: "https://blog.example/1"},
+		{Title: "bbc", Source: Source{ID: "bbc-news"}, URL: "https://bbc.co.uk/1"},
+		{Title: "reuters", URL: "https://www.reuters.com/1"},
+	}
+
+	if got := titles(filterTrusted(articles, set)); !reflect.DeepEqual(got, []string{"bbc", "reuters"}) {
+		t.Errorf("filterTrusted = %v, want [bbc reuters]", got)
+	}
+}
+
+func TestVerifiedBadge(t *testing.T) {
+	useIndexTemplate(t)
+	search := &Search{
+		SearchKey:   "news",
+		CurrentPage: 1,
+		TotalPages:  1,
+		Locale:      defaultLocale,
+		Trusted:     parseTrustSet("reuters.com"),
+		Results: Results{TotalResults: 2, Articles: []Article{
+			{Title: "Trusted story", URL: "https://www.reuters.com/1"},
+			{Title: "Other story", URL: "https://blog.example/1"},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, search); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if n := strings.Count(buf.String(), `class="verified-badge"`); n != 1 {
+		t.Errorf("verified badge rendered %d times, want once", n)
+	}
+}
+
+func TestTrustedOnlyCountIsPerPage(t *testing.T) {
+	useIndexTemplate(t)
+	search := &Search{
+		SearchKey:   "news",
+		CurrentPage: 2,
+		TotalPages:  5,
+		Locale:      defaultLocale,
+		TrustedOnly: true,
+		Trusted:     parseTrustSet("reuters.com"),
+		Results: Results{TotalResults: 100, Articles: []Article{
+			{Title: "Trusted story", URL: "https://www.reuters.com/1"},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, search); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if body := buf.String(); !strings.Contains(body, "<strong>1</strong> articles from verified sources on page <strong>2</strong>") || strings.Contains(body, "<strong>100</strong>") {
+		t.Errorf("result count does not say the filter is per page:\n%s", body)
+	}
+}
+
+func TestPinTrustedIsStable(t *testing.T) {
+	set := parseTrustSet("bbc-news,reuters.com")
+	articles := []Article{
+		{Title: "blog 1", URL: "https://blog.example/1"},
+		{Title: "bbc 1", Source: Source{ID: "bbc-news"}, URL: "https://bbc.co.uk/1"},
+		{Title: "blog 2", URL: "https://blog.example/2"},
+		{Title: "reuters 1", URL: "https://www.reuters.com/1"},
+		{Title: "bbc 2", Source: Source{ID: "bbc-news"}, URL: "https://bbc.co.uk/2"},
+		{Title: "blog 3", URL: "https://blog.example/3"},
+	}
+
+	got := titles(pinTrusted(articles, set))
+	want := []string{"bbc 1", "reuters 1", "bbc 2", "blog 1", "blog 2", "blog 3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pinTrusted = %v, want %v", got, want)
+	}
+}
+
+func TestPinTrustedWithoutTrustedSources(t *testing.T) {
+	articles := []Article{{Title: "a"}, {Title: "b"}}
+	if got := titles(pinTrusted(articles, trustSet{})); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("pinTrusted with empty set = %v, want unchanged", got)
+	}
+}
+
+func TestRunSearchPinPopular(t *testing.T) {
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok","totalResults":3,"articles":[
+			{"source":{"id":null,"name":"Blog"},"title":"newest","url":"https://blog.example/1","publishedAt":"2024-05-03T00:00:00Z"},
+			{"source":{"id":"bbc-news","name":"BBC"},"title":"trusted","url":"https://bbc.co.uk/1","publishedAt":"2024-05-02T00:00:00Z"},
+			{"source":{"id":null,"name":"Blog"},"title":"oldest","url":"https://blog.example/2","publishedAt":"2024-05-01T00:00:00Z"}]}`))
+	})
+	old := trustedSources
+	trustedSources = parseTrustSet("bbc-news")
+	t.Cleanup(func() { trustedSources = old })
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/search?q=news", []string{"newest", "trusted", "oldest"}},
+		{"/search?q=news&pinPopular=1", []string{"trusted", "newest", "oldest"}},
+		{"/search?q=news&pinPopular=1&sortBy=relevancy", []string{"newest", "trusted", "oldest"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		search, ok := runSearch(rec, httptest.NewRequest(http.MethodGet, tt.target, nil), false)
+		if !ok {
+			t.Fatalf("%s: runSearch failed: %d %s", tt.target, rec.Code, rec.Body)
+		}
+		if got := titles(search.Results.Articles); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: order = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	search, _ := runSearch(rec, httptest.NewRequest(http.MethodGet, "/search?q=news&pinPopular=true", nil), false)
+	if got := search.query(2).Get("pinPopular"); got != "1" {
+		t.Errorf("pagination query pinPopular = %q, want 1", got)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+var (
+	upstreamIdleConns   = 32               // Сколько простаивающих соединений с NewsAPI держать открытыми
+	upstreamIdleTimeout = 90 * time.Second // Сколько держать простаивающее соединение
+	upstreamCompression = true             // Просить у NewsAPI gzip: JSON выдачи хорошо сжимается
+)
+
+// newNewsTransport — транспорт для единственного хоста NewsAPI. У стандартного
+// MaxIdleConnsPerHost равен 2, и под нагрузкой лишние соединения закрываются
+// после каждого ответа, а новые снова проходят TCP и TLS.
+func newNewsTransport(idleConns int, idleTimeout time.Duration, compression bool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone() // Прокси из окружения, таймауты dial и TLS, HTTP/2
+	t.MaxIdleConns = idleConns
+	t.MaxIdleConnsPerHost = idleConns
+	t.IdleConnTimeout = idleTimeout
+	t.DisableCompression = !compression
+	return t
+}
+
+// newsAPIError — ошибка, которую NewsAPI вернул в теле ответа
+// ({"status":"error","code":...,"message":...}). Такое тело бывает и при
+// HTTP 200, поэтому проверять одного кода ответа мало.
+type newsAPIError struct {
+	StatusCode int    // HTTP-код ответа NewsAPI
+	Code       string // Например apiKeyInvalid, rateLimited, parameterInvalid
+	Message    string
+}
+
+func (e *newsAPIError) Error() string {
+	return fmt.Sprintf("NewsAPI error %s (HTTP %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// Unwrap относит ошибку NewsAPI к одному из видов ошибок getNews по коду,
+// а для незнакомого кода — по HTTP-статусу.
+func (e *newsAPIError) Unwrap() error {
+	switch e.Code {
+	case "parameterInvalid", "parametersMissing", "sourcesTooMany", "sourceDoesNotExist", "maximumResultsReached":
+		return ErrBadQuery
+	case "rateLimited", "apiKeyExhausted":
+		return ErrRateLimited
+	case "apiKeyInvalid", "apiKeyMissing", "apiKeyDisabled":
+		return ErrUnauthorized
+	}
+	return statusErrorKind(e.StatusCode)
+}
+
+// Виды ошибок getNews: конкретные ошибки оборачивают один из них, чтобы
+// обработчики выбирали HTTP-статус через errors.Is (см. errorKindStatus), а в
+// журнал попадала исходная причина.
+var (
+	ErrBadQuery            = errors.New("NewsAPI rejected the query")
+	ErrRateLimited         = errors.New("NewsAPI rate limit reached")
+	ErrUnauthorized        = errors.New("NewsAPI rejected the API key")
+	ErrUpstreamUnavailable = errors.New("NewsAPI is unavailable")
+)
+
+// statusErrorKind — вид ошибки для ответа NewsAPI с кодом status, если тело
+// ответа ничего не объяснило.
+func statusErrorKind(status int) error {
+	switch status {
+	case http.StatusBadRequest:
+		return ErrBadQuery
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	}
+	return ErrUpstreamUnavailable
+}
+
+// errorKindStatus — HTTP-статус нашего ответа для вида ошибки err; 500, если
+// err ни к одному виду не относится.
+func errorKindStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrBadQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// parseNewsAPIError достает ошибку из тела ответа NewsAPI. Возвращает nil,
+// если тело не похоже на ответ об ошибке.
+func parseNewsAPIError(statusCode int, body []byte) *newsAPIError {
+	var r Results
+	if json.Unmarshal(body, &r) != nil || r.Status != "error" {
+		return nil
+	}
+	return &newsAPIError{StatusCode: statusCode, Code: r.Code, Message: r.Message}
+}
+
+// decodeNewsAPIResponse проверяет ответ NewsAPI и разбирает его JSON в v.
+// Ответ с кодом не 200 становится *newsAPIError из тела или, если тело не
+// разобрать, ошибкой вида по коду. Тело со status "error" при коде 200 тоже
+// *newsAPIError. Сбои пишутся в журнал и считаются в upstreamErrors.
+func decodeNewsAPIResponse(ctx context.Context, resp *http.Response, v any) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		slog.ErrorContext(ctx, "NewsAPI response read error", "error", err)
+		upstreamErrors.Inc("decode")
+		return fmt.Errorf("%w: read response: %w", ErrUpstreamUnavailable, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		slog.ErrorContext(ctx, "NewsAPI status code error", "status_code", resp.StatusCode, "body", string(body))
+		upstreamErrors.Inc("status")
+		if apiErr := parseNewsAPIError(resp.StatusCode, body); apiErr != nil {
+			return apiErr
+		}
+		return fmt.Errorf("%w: API status code error: %d", statusErrorKind(resp.StatusCode), resp.StatusCode)
+	}
+	if apiErr := parseNewsAPIError(resp.StatusCode, body); apiErr != nil {
+		slog.ErrorContext(ctx, "NewsAPI returned an error body", "code", apiErr.Code, "message", apiErr.Message)
+		upstreamErrors.Inc("api_error")
+		return apiErr
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		slog.ErrorContext(ctx, "NewsAPI JSON decode error", "error", err)
+		upstreamErrors.Inc("decode")
+		return fmt.Errorf("%w: JSON decode error: %w", ErrUpstreamUnavailable, err)
+	}
+	return nil
+}
+
+// newsAPIErrorStatus подбирает статус и сообщение для пользователя по коду
+// ошибки NewsAPI. Неверные параметры — вина запроса, остальное — сбой сервиса.
+func newsAPIErrorStatus(e *newsAPIError) (int, string) {
+	status := errorKindStatus(e)
+	switch e.Code {
+	case "parameterInvalid", "parametersMissing", "sourcesTooMany", "sourceDoesNotExist":
+		return status, "News service rejected the search: " + e.Message
+	case "rateLimited", "apiKeyExhausted":
+		return status, "News service is rate limiting us, try again later"
+	case "maximumResultsReached":
+		return status, fmt.Sprintf("Free plan limits results to the first %d; refine your search", planResultLimit())
+	case "apiKeyInvalid", "apiKeyMissing", "apiKeyDisabled":
+		return status, "News service rejected our API key (" + e.Code + ")"
+	}
+	if e.Message == "" {
+		return status, "News service returned an error"
+	}
+	return status, "News service returned an error: " + e.Message
+}
+
+// developerPlanResults — сколько первых результатов поиска NewsAPI отдает на
+// бесплатном тарифе Developer. Дальше он отвечает maximumResultsReached.
+const developerPlanResults = 100
+
+var resultLimit = developerPlanResults // Сколько результатов можно пролистать; 0 — без ограничения (платный тариф)
+
+// planResultLimit — ограничение, о котором говорим посетителю. Если
+// NewsAPI ответил maximumResultsReached при выключенном -resultlimit, значит,
+// тариф все-таки Developer.
+func planResultLimit() int {
+	if resultLimit > 0 {
+		return resultLimit
+	}
+	return developerPlanResults
+}
+
+// lastAllowedPage — последняя страница размера pageSize в пределах limit
+// результатов; 0, если ограничения нет.
+func lastAllowedPage(limit, pageSize int) int {
+	if limit <= 0 || pageSize <= 0 {
+		return 0
+	}
+	return max(limit/pageSize, 1)
+}
+
+// resultLimitError превращает maximumResultsReached в pageRangeError: как и
+// для страницы за концом выдачи, посетителя отправят на последнюю доступную.
+// Если он уже на ней, остается исходная ошибка.
+func resultLimitError(e *newsAPIError, page, pageSize int) error {
+	if e.Code != "maximumResultsReached" {
+		return e
+	}
+	if last := lastAllowedPage(planResultLimit(), pageSize); page > last {
+		return &pageRangeError{Page: page, LastPage: last}
+	}
+	return e
+}
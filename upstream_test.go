@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// BenchmarkRequestNewsConcurrent сравнивает стандартный транспорт с
+// newNewsTransport при параллельных запросах. conns/op — сколько новых
+// соединений пришлось открыть на запрос.
+//
+//	go test -run '^$' -bench RequestNewsConcurrent -cpu 32
+func BenchmarkRequestNewsConcurrent(b *testing.B) {
+	body := articlesJSON(100, 20)
+	var conns atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond) // Сколько-то NewsAPI думает над ответом
+		io.WriteString(w, body)
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	old := httpClient.Transport
+	b.Cleanup(func() { httpClient.Transport = old })
+
+	transports := []struct {
+		name      string
+		transport *http.Transport
+	}{
+		{"default", http.DefaultTransport.(*http.Transport).Clone()},
+		{"tuned", newNewsTransport(upstreamIdleConns, upstreamIdleTimeout, upstreamCompression)},
+	}
+	for _, tt := range transports {
+		b.Run(tt.name, func(b *testing.B) {
+			httpClient.Transport = tt.transport
+			defer tt.transport.CloseIdleConnections()
+			conns.Store(0)
+			endpoint := srv.URL + "/v2/everything?q=golang&apiKey=test-key"
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := requestNews(context.Background(), endpoint, &quotaState{})
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+			})
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
+
+func TestNewNewsTransport(t *testing.T) {
+	tr := newNewsTransport(16, time.Minute, false)
+	if tr.MaxIdleConnsPerHost != 16 || tr.MaxIdleConns != 16 {
+		t.Errorf("idle conns = %d per host, %d total, want 16", tr.MaxIdleConnsPerHost, tr.MaxIdleConns)
+	}
+	if tr.IdleConnTimeout != time.Minute {
+		t.Errorf("IdleConnTimeout = %v, want 1m", tr.IdleConnTimeout)
+	}
+	if !tr.DisableCompression {
+		t.Error("DisableCompression = false, want true")
+	}
+	if tr.Proxy == nil {
+		t.Error("Proxy is nil, want the proxy from the environment as in http.DefaultTransport")
+	}
+}
+
+const maximumResultsJSON = `{"status":"error","code":"maximumResultsReached","message":"You have requested too many results. Developer accounts are limited to a max of 100 results. You are trying to request results 100 to 120. Please upgrade to a paid plan if you need more results."}`
+
+// newLimitedNewsAPI отвечает как NewsAPI на тарифе Developer: 500 результатов,
+// но дальше первых 100 — maximumResultsReached. Возвращает запрошенные страницы.
+func newLimitedNewsAPI(t *testing.T) *[]string {
+	var pages []string
+	newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		if page != "1" && page != "2" && page != "3" && page != "4" && page != "5" {
+			w.WriteHeader(http.StatusUpgradeRequired)
+			fmt.Fprint(w, maximumResultsJSON)
+			return
+		}
+		fmt.Fprint(w, articlesJSON(500, 20))
+	})
+	return &pages
+}
+
+func TestResultLimitClampsPagination(t *testing.T) {
+	pages := newLimitedNewsAPI(t)
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+	if !strings.Contains(rec.Body.String(), `class="result-limit"`) {
+		t.Error("page does not explain the result limit")
+	}
+	if strings.Contains(rec.Body.String(), "page=6") {
+		t.Error("page links to page 6, which NewsAPI refuses")
+	}
+
+	rec = httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&page=9", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("page 9: status = %d, want %d", rec.Code, http.StatusFound)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if got := loc.Query().Get("page"); got != "5" {
+		t.Errorf("page 9: redirect page = %q, want 5", got)
+	}
+	for _, p := range *pages {
+		if p == "9" {
+			t.Error("page 9 was requested from NewsAPI")
+		}
+	}
+}
+
+func TestMaximumResultsReachedError(t *testing.T) {
+	newLimitedNewsAPI(t)
+	old := resultLimit
+	resultLimit = 0 // Думаем, что тариф платный, а NewsAPI считает иначе
+	t.Cleanup(func() { resultLimit = old })
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang&page=7", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("page 7: status = %d, want %d; body: %s", rec.Code, http.StatusFound, rec.Body)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	if got := loc.Query().Get("page"); got != "5" {
+		t.Errorf("page 7: redirect page = %q, want 5", got)
+	}
+
+	status, msg := newsAPIErrorStatus(parseNewsAPIError(http.StatusUpgradeRequired, []byte(maximumResultsJSON)))
+	if status != http.StatusBadRequest || !strings.Contains(msg, "first 100") {
+		t.Errorf("newsAPIErrorStatus = %d %q, want 400 explaining the limit", status, msg)
+	}
+}
+
+func TestParseNewsAPIError(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		isErr bool
+		code  string
+	}{
+		{"error body", `{"status":"error","code":"apiKeyInvalid","message":"Your API key is invalid"}`, true, "apiKeyInvalid"},
+		{"error without code", `{"status":"error"}`, true, ""},
+		{"ok body", `{"status":"ok","totalResults":0,"articles":[]}`, false, ""},
+		{"not JSON", `<html>Bad Gateway</html>`, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := parseNewsAPIError(http.StatusUnauthorized, []byte(tt.body))
+			if !tt.isErr {
+				if apiErr != nil {
+					t.Errorf("parseNewsAPIError = %v, want nil", apiErr)
+				}
+				return
+			}
+			if apiErr == nil || apiErr.Code != tt.code || apiErr.StatusCode != http.StatusUnauthorized {
+				t.Errorf("parseNewsAPIError = %+v, want code %q with HTTP 401", apiErr, tt.code)
+			}
+		})
+	}
+}
+
+func TestErrorBodyWith200(t *testing.T) {
+	calls := stubNewsAPI(t, `{"status":"error","code":"unexpectedError","message":"oops"}`)
+	resultsCache = newTTLCache[Results](time.Minute, 10) // Ошибка не должна попасть в кэш
+
+	_, err := getNews(context.Background(), newsQuery{Query: "golang"}, 20, 1)
+	var apiErr *newsAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "unexpectedError" || apiErr.StatusCode != http.StatusOK {
+		t.Fatalf("getNews error = %v, want a newsAPIError with HTTP 200", err)
+	}
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("getNews error = %v, want it to wrap ErrUpstreamUnavailable", err)
+	}
+
+	rec := httptest.NewRecorder()
+	searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d; body: %s", rec.Code, http.StatusBadGateway, rec.Body)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("NewsAPI calls = %d, want 2: error bodies must not be cached", calls.Load())
+	}
+}
+
+func TestSearchHandlerUpstreamErrorStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiStatus int
+		body      string
+		want      int
+		kind      error
+	}{
+		{"bad query", http.StatusBadRequest, `{"status":"error","code":"parameterInvalid","message":"q is too long"}`, http.StatusBadRequest, ErrBadQuery},
+		{"rate limited", http.StatusTooManyRequests, `{"status":"error","code":"rateLimited","message":"slow down"}`, http.StatusTooManyRequests, ErrRateLimited},
+		{"bad API key", http.StatusUnauthorized, `{"status":"error","code":"apiKeyInvalid","message":"invalid key"}`, http.StatusUnauthorized, ErrUnauthorized},
+		{"unexplained 401", http.StatusUnauthorized, `Unauthorized`, http.StatusUnauthorized, ErrUnauthorized},
+		{"upstream down", http.StatusServiceUnavailable, `<html>Service Unavailable</html>`, http.StatusBadGateway, ErrUpstreamUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.apiStatus)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := getNews(context.Background(), newsQuery{Query: "golang"}, 20, 1)
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("getNews error = %v, want it to wrap %v", err, tt.kind)
+			}
+
+			rec := httptest.NewRecorder()
+			searchHandler(rec, httptest.NewRequest(http.MethodGet, "/search?q=golang", nil))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestErrorKindsWrapCause(t *testing.T) {
+	for _, err := range []error{ErrQuotaExhausted, ErrAllKeysExhausted} {
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("%v does not wrap ErrRateLimited", err)
+		}
+	}
+
+	cause := errors.New("connection refused")
+	err := upstreamError(cause)
+	if !errors.Is(err, ErrUpstreamUnavailable) || !errors.Is(err, cause) {
+		t.Errorf("upstreamError(%v) = %v, want it to wrap ErrUpstreamUnavailable and the cause", cause, err)
+	}
+	if err := upstreamError(context.DeadlineExceeded); !errors.Is(err, ErrUpstreamTimeout) || !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("timeout error %v should wrap ErrUpstreamTimeout and ErrUpstreamUnavailable", err)
+	}
+}
+
+func TestDecodeNewsAPIResponse(t *testing.T) {
+	response := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+	}
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantKind error
+		wantCode string // Код *newsAPIError; пустой — ошибка не из тела
+	}{
+		{"error body", http.StatusUnauthorized, `{"status":"error","code":"apiKeyInvalid","message":"bad key"}`, ErrUnauthorized, "apiKeyInvalid"},
+		{"error body with 200", http.StatusOK, `{"status":"error","code":"rateLimited","message":"slow down"}`, ErrRateLimited, "rateLimited"},
+		{"status without body", http.StatusBadGateway, "<html>Bad gateway</html>", ErrUpstreamUnavailable, ""},
+		{"broken JSON", http.StatusOK, `{"status":"ok","sources":[`, ErrUpstreamUnavailable, ""},
+	}
+	for _, tt := range tests {
+		var decoded sourcesResponse
+		err := decodeNewsAPIResponse(context.Background(), response(tt.status, tt.body), &decoded)
+		if !errors.Is(err, tt.wantKind) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantKind)
+		}
+		var apiErr *newsAPIError
+		gotCode := ""
+		if errors.As(err, &apiErr) {
+			gotCode = apiErr.Code
+		}
+		if gotCode != tt.wantCode {
+			t.Errorf("%s: newsAPIError code = %q, want %q", tt.name, gotCode, tt.wantCode)
+		}
+	}
+
+	var decoded sourcesResponse
+	body := `{"status":"ok","sources":[{"id":"bbc-news","name":"BBC News"}]}`
+	if err := decodeNewsAPIResponse(context.Background(), response(http.StatusOK, body), &decoded); err != nil || len(decoded.Sources) != 1 || decoded.Sources[0].ID != "bbc-news" {
+		t.Errorf("ok response: sources = %+v, err = %v", decoded.Sources, err)
+	}
+}
+
+func TestRequestNewsRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       int
+		backoff    time.Duration
+		statuses   []int  // Ответы подставного NewsAPI по порядку; последний повторяется
+		retryAfter string // Retry-After для ответов 429
+		wantCalls  int
+		wantStatus int
+	}{
+		{"success", 1, time.Millisecond, []int{200}, "", 1, 200},
+		{"retries 5xx", 1, time.Millisecond, []int{503, 502, 200}, "", 3, 200},
+		{"stops at the attempt cap", 1, time.Millisecond, []int{500}, "", 3, 500},
+		{"does not retry 4xx", 1, time.Millisecond, []int{400}, "", 1, 400},
+		{"Retry-After replaces the backoff", 1, time.Hour, []int{429, 200}, "0", 2, 200},
+		{"too long Retry-After is returned", 1, time.Millisecond, []int{429, 200}, "3600", 1, 429},
+		{"too long backoff is returned", 1, time.Hour, []int{503, 200}, "", 1, 503},
+		{"single key retries 429", 1, time.Millisecond, []int{429, 200}, "", 2, 200},
+		{"several keys return 429 at once", 2, time.Millisecond, []int{429, 200}, "", 1, 429},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			srv := newMockNewsAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(calls, len(tt.statuses)-1)]
+				calls++
+				if status == http.StatusTooManyRequests && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+				fmt.Fprint(w, articlesJSON(1, 1))
+			})
+			keys := []string{"test-key", "spare-key"}[:tt.keys]
+			oldBackoff := retryBackoff
+			apiKeys, maxAttempts, retryBackoff = newKeyRing(keys), 3, tt.backoff
+			defer func() { retryBackoff = oldBackoff }()
+
+			resp, err := requestNews(context.Background(), srv.URL+"/v2/everything?q=go", &quotaState{})
+			if err != nil {
+				t.Fatalf("requestNews: %v", err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if calls != tt.wantCalls || resp.StatusCode != tt.wantStatus {
+				t.Errorf("calls = %d, status = %d; want %d calls ending in %d", calls, resp.StatusCode, tt.wantCalls, tt.wantStatus)
+			}
+		})
+	}
+}
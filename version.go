@@ -0,0 +1,17 @@
+package main
+
+import "fmt"
+
+// Сведения о сборке, задаются при сборке:
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD) -X main.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// versionString — строка, которую печатает -version и пишет лог при старте.
+func versionString() string {
+	return fmt.Sprintf("news-site %s (commit %s, built %s)", version, commit, date)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lastVisitCookie = "lastvisit"
+
+var trackVisits bool // Отмечать ли статьи, вышедшие после прошлого визита
+
+var clock = time.Now // Текущее время; подменяется, когда нужны фиксированные часы
+
+// visitGap — после скольких минут без запросов следующий запрос считается
+// новым визитом. Внутри визита отметка прошлого визита не меняется, поэтому
+// ключ кэша страниц остается прежним, пока посетитель листает выдачу.
+const visitGap = 30 * time.Minute
+
+// visitTimes разбирает cookie визитов: "начало прошлого визита.последний
+// запрос" в unix-секундах. Старая cookie с одним числом считается визитом,
+// для которого оба времени совпадают.
+func visitTimes(r *http.Request) (previous, seen time.Time, ok bool) {
+	c, err := r.Cookie(lastVisitCookie)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	prevStr, seenStr, found := strings.Cut(c.Value, ".")
+	if !found {
+		seenStr = prevStr
+	}
+	prevSecs, err1 := strconv.ParseInt(prevStr, 10, 64)
+	seenSecs, err2 := strconv.ParseInt(seenStr, 10, 64)
+	if err1 != nil || err2 != nil || prevSecs <= 0 || seenSecs < prevSecs {
+		return time.Time{}, time.Time{}, false
+	}
+	return time.Unix(prevSecs, 0), time.Unix(seenSecs, 0), true
+}
+
+// previousVisit возвращает время прошлого визита из cookie или нулевое время
+// для новых посетителей и при выключенной функции. Если с последнего запроса
+// прошло больше visitGap, прошлым визитом становится этот запрос.
+func previousVisit(r *http.Request) time.Time {
+	if !trackVisits {
+		return time.Time{}
+	}
+	previous, seen, ok := visitTimes(r)
+	if !ok {
+		return time.Time{}
+	}
+	if clock().Sub(seen) > visitGap {
+		return seen
+	}
+	return previous
+}
+
+// touchLastVisit запоминает в cookie время запроса и отметку прошлого визита.
+// Новому посетителю ею становится первый запрос.
+func touchLastVisit(w http.ResponseWriter, r *http.Request) {
+	if !trackVisits {
+		return
+	}
+	now := clock()
+	previous := previousVisit(r)
+	if previous.IsZero() {
+		previous = now
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     lastVisitCookie,
+		Value:    strconv.FormatInt(previous.Unix(), 10) + "." + strconv.FormatInt(now.Unix(), 10),
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// markNewSince отмечает статьи, опубликованные после since.
+// При нулевом since (первый визит) ничего не отмечается.
+func markNewSince(articles []Article, since time.Time) {
+	if since.IsZero() {
+		return
+	}
+	for i := range articles {
+		articles[i].NewSinceVisit = articles[i].PublishedAt.After(since)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLastVisitCookie(t *testing.T) {
+	trackVisits = true
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	oldClock := clock
+	clock = func() time.Time { return now }
+	defer func() { clock = oldClock }()
+
+	// visit выполняет запрос с cookie прошлого ответа и возвращает новую.
+	visit := func(c *http.Cookie) (time.Time, *http.Cookie) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/search?q=go", nil)
+		if c != nil {
+			req.AddCookie(c)
+		}
+		previous := previousVisit(req)
+		rec := httptest.NewRecorder()
+		touchLastVisit(rec, req)
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != lastVisitCookie {
+			t.Fatalf("cookies = %v, want one %s cookie", cookies, lastVisitCookie)
+		}
+		return previous, cookies[0]
+	}
+
+	previous, c := visit(nil)
+	if !previous.IsZero() || c.Value != "1714564800.1714564800" {
+		t.Fatalf("first visit: previousVisit = %v, cookie = %q; want zero and 1714564800.1714564800", previous, c.Value)
+	}
+
+	// Пока посетитель активен, отметка не двигается и ключ кэша страниц тот же
+	for i := 0; i < 3; i++ {
+		now = now.Add(20 * time.Minute)
+		if previous, c = visit(c); !previous.Equal(start) {
+			t.Errorf("request %d in the same visit: previousVisit = %v, want %v", i, previous, start)
+		}
+	}
+
+	lastSeen := now
+	now = now.Add(visitGap + time.Minute)
+	if previous, c = visit(c); !previous.Equal(lastSeen) {
+		t.Errorf("after a gap: previousVisit = %v, want the last request %v", previous, lastSeen)
+	}
+	now = now.Add(time.Minute)
+	if previous, _ = visit(c); !previous.Equal(lastSeen) {
+		t.Errorf("next request of the new visit: previousVisit = %v, want %v", previous, lastSeen)
+	}
+
+	legacy := httptest.NewRequest(http.MethodGet, "/", nil)
+	legacy.AddCookie(&http.Cookie{Name: lastVisitCookie, Value: "1714564800"})
+	if got := previousVisit(legacy); !got.Equal(start) {
+		t.Errorf("previousVisit with a single-time cookie = %v, want %v", got, start)
+	}
+
+	for _, value := range []string{"yesterday", "1714564800.soon", "1714568400.1714564800"} {
+		bad := httptest.NewRequest(http.MethodGet, "/", nil)
+		bad.AddCookie(&http.Cookie{Name: lastVisitCookie, Value: value})
+		if got := previousVisit(bad); !got.IsZero() {
+			t.Errorf("previousVisit with cookie %q = %v, want zero", value, got)
+		}
+	}
+}
+
+func TestMarkNewSince(t *testing.T) {
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	articles := []Article{
+		{Title: "after", PublishedAt: since.Add(time.Minute)},
+		{Title: "equal", PublishedAt: since},
+		{Title: "before", PublishedAt: since.Add(-time.Hour)},
+		{Title: "undated"},
+	}
+
+	markNewSince(articles, since)
+	for i, want := range []bool{true, false, false, false} {
+		if articles[i].NewSinceVisit != want {
+			t.Errorf("%s: NewSinceVisit = %t, want %t", articles[i].Title, articles[i].NewSinceVisit, want)
+		}
+	}
+
+	first := []Article{{Title: "any", PublishedAt: since}}
+	markNewSince(first, time.Time{})
+	if first[0].NewSinceVisit {
+		t.Error("articles marked as new on the first visit")
+	}
+}